@@ -0,0 +1,28 @@
+// Package api встраивает в бинарник OpenAPI спецификацию сервиса: по ней проверяются входящие запросы,
+// она же отдаётся клиентам на /api/v1/openapi.json
+package api
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Spec - спецификация в том виде, в котором она лежит в репозитории
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// Load разбирает спецификацию и проверяет, что она сама корректна (ссылки, схемы, параметры путей)
+func Load() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(Spec)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать спецификацию OpenAPI: %w", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("невалидная спецификация OpenAPI: %w", err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"RedCollar/internal/config"
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"RedCollar/internal/service"
+)
+
+const apikeyUsage = `использование: main apikey [-tenant SLUG] <команда>
+  issue NAME SCOPES [TTL]  выпустить ключ, SCOPES через запятую (incidents:read,incidents:write,...),
+                           TTL - срок действия, например 720h (по умолчанию бессрочный)
+  list                     показать все ключи
+  revoke ID                отозвать ключ
+  -tenant SLUG             организация ключей (по умолчанию - организация default)`
+
+// runAPIKey управляет API ключами из командной строки, например чтобы выпустить первый ключ без API_KEY
+func runAPIKey(ctx context.Context, cfg *config.Config, args []string) error {
+	var slug string
+	if len(args) > 1 && args[0] == "-tenant" {
+		slug, args = args[1], args[2:]
+	}
+	if len(args) == 0 {
+		return errors.New(apikeyUsage)
+	}
+
+	db, err := repository.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	keys := service.NewAPIKeyService(db, "")
+
+	//все команды выполняются в рамках одной организации, как и запросы к API
+	if slug != "" {
+		tenant, err := service.NewTenantService(db).GetBySlug(ctx, slug)
+		if err != nil {
+			return fmt.Errorf("организация %q: %w", slug, err)
+		}
+		ctx = domain.WithTenant(ctx, tenant)
+	}
+
+	switch args[0] {
+	case "issue":
+		if len(args) < 3 {
+			return errors.New(apikeyUsage)
+		}
+		req := domain.IssueAPIKeyRequest{Name: args[1]}
+		for _, scope := range strings.Split(args[2], ",") {
+			req.Scopes = append(req.Scopes, domain.Scope(strings.TrimSpace(scope)))
+		}
+		if len(args) > 3 {
+			ttl, err := time.ParseDuration(args[3])
+			if err != nil {
+				return fmt.Errorf("некорректный TTL: %w", err)
+			}
+			expiresAt := time.Now().Add(ttl)
+			req.ExpiresAt = &expiresAt
+		}
+		issued, err := keys.Issue(ctx, req)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "ID:    %s\nключ:  %s\n(ключ показывается один раз, сохраните его)\n", issued.ID, issued.Token)
+		return nil
+	case "list":
+		list, err := keys.List(ctx)
+		if err != nil {
+			return err
+		}
+		printAPIKeys(list)
+		return nil
+	case "revoke":
+		if len(args) < 2 {
+			return errors.New(apikeyUsage)
+		}
+		return keys.Revoke(ctx, args[1])
+	default:
+		return errors.New(apikeyUsage)
+	}
+}
+
+// printAPIKeys выводит ключи таблицей
+func printAPIKeys(keys []*domain.APIKey) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tИМЯ\tПРЕФИКС\tПРАВА\tИСТЕКАЕТ\tИСПОЛЬЗОВАН\tОТОЗВАН")
+	for _, key := range keys {
+		scopes := make([]string, len(key.Scopes))
+		for idx, scope := range key.Scopes {
+			scopes[idx] = string(scope)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, strings.Join(scopes, ","),
+			formatTime(key.ExpiresAt), formatTime(key.LastUsedAt), formatTime(key.RevokedAt))
+	}
+	_ = w.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -1,63 +1,66 @@
 package main
 
 import (
-	"RedCollar/internal/worker"
 	"context"
-	"log"
+	"log/slog"
+	"os"
 	"os/signal"
 	"syscall"
 
+	"RedCollar/internal/app"
 	"RedCollar/internal/config"
-	v1 "RedCollar/internal/delivery/http/v1"
-	"RedCollar/internal/repository"
-	"RedCollar/internal/service"
+	"RedCollar/internal/logger"
 )
 
 func main() {
 	//создаём контекст для управления программой
-	//при нажатии ctrl+c отправляем сигнал и отключаемся
+	//при нажатии ctrl+c или SIGTERM от оркестратора отправляем сигнал и корректно отключаемся
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	//логи пишем в JSON, уровень из конфига применяем сразу после его чтения
+	logger.Init()
+
 	//читаем и записываем переменные из .env в переменную cfg
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("не удалось получить данные из .env: %v", err)
+		fatal("не удалось получить данные из .env", err)
 	}
+	_ = logger.SetLevel(cfg.LogLevel) //уровень уже проверен при валидации конфига
 
-	//устанавливаем подключение с PostgreSQL
-	db, err := repository.NewPostgresConnection(ctx, cfg)
-	if err != nil {
-		log.Fatal("не удалось подключиться к БД(postgres):", err)
+	//main migrate <команда> управляет схемой БД и завершается, не запуская сервис
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			fatal("ошибка миграции", err)
+		}
+		return
 	}
 
-	//устанавливаем подключение с Redis
-	rdb, err := repository.RedisConnection(ctx, cfg.RedisAddr)
-	if err != nil {
-		log.Fatal("не удалось подключиться к БД(redis):", err)
+	//main apikey <команда> выпускает, показывает и отзывает API ключи
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		if err := runAPIKey(ctx, cfg, os.Args[2:]); err != nil {
+			fatal("ошибка управления API ключами", err)
+		}
+		return
 	}
 
-	//инициализируем сервис
-	serv := service.NewIncidentService(db, rdb, cfg.WarningZone, cfg.CacheTTL)
-
-	//инициализируем HTTP клиента
-	client := service.NewHTTPClient(cfg.WebhookTimeout)
-
-	//инициализируем воркера
-	w := worker.NewWebhookWorker(rdb, client, cfg.WebhookUrl, cfg.WebhookRetries)
-
-	//запускаем в воркера в горутине чтобы не блокировать основное выполнение программы
-	go func() {
-		w.Run(ctx)
-	}()
-
-	//инициализируем сервер
-	h := v1.NewHandler(serv, cfg.StatsTime)
-
-	log.Printf("сервер запущен на порту: %s", cfg.AppPort)
+	//main retention <команда> применяет срок хранения проверок координат или секционирует их таблицу
+	if len(os.Args) > 1 && os.Args[1] == "retention" {
+		if err := runRetention(ctx, cfg, os.Args[2:]); err != nil {
+			fatal("ошибка применения срока хранения", err)
+		}
+		return
+	}
 
-	//запускаем сервер
-	if err := h.Run(cfg.AppPort, cfg.ApiKey); err != nil {
-		log.Fatal("ошибка запуска сервера:", err)
+	//запускаем приложение, Run возвращается только после полной остановки
+	if err := app.New(cfg).Run(ctx); err != nil {
+		fatal("сервис остановлен с ошибкой", err)
 	}
+	slog.Info("сервис остановлен")
+}
+
+// fatal логирует ошибку и завершает процесс
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
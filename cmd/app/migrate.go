@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"RedCollar/internal/config"
+	"RedCollar/internal/migrator"
+)
+
+const migrateUsage = `использование: main migrate <команда>
+  up           применить все новые миграции
+  down [N]     откатить N последних миграций (по умолчанию 1)
+  status       показать текущую версию схемы и список миграций
+  force V      записать версию V без выполнения миграций (снимает флаг dirty)`
+
+// runMigrate выполняет подкоманду migrate и возвращает ошибку вместо запуска сервиса
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := migrator.New(cfg.PostgresDSN)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		return m.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return errors.New("количество миграций для отката должно быть числом")
+			}
+		}
+		return m.Down(steps)
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errors.New("версия должна быть числом")
+		}
+		return m.Force(version)
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		printStatus(status)
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// printStatus выводит состояние миграций в читаемом виде, это вывод для человека, а не лог
+func printStatus(status migrator.Status) {
+	fmt.Fprintf(os.Stdout, "версия схемы: %d", status.Version)
+	if status.Dirty {
+		fmt.Fprint(os.Stdout, " (dirty: миграция упала, исправьте схему и выполните force)")
+	}
+	fmt.Fprintln(os.Stdout)
+	for _, migration := range status.Migrations {
+		mark := " "
+		if migration.Applied {
+			mark = "x"
+		}
+		fmt.Fprintf(os.Stdout, "[%s] %06d %s\n", mark, migration.Version, migration.Name)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"RedCollar/internal/config"
+	"RedCollar/internal/repository"
+	"RedCollar/internal/service"
+)
+
+const retentionUsage = `использование: main retention <команда>
+  run          один раз применить LOCATION_RETENTION_DAYS: свернуть старые проверки в почасовые итоги и удалить их
+  partition    секционировать location_checks по дням, после этого старые проверки удаляются целыми секциями`
+
+// runRetention управляет сроком хранения проверок координат из командной строки
+func runRetention(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(retentionUsage)
+	}
+
+	db, err := repository.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	retention := service.NewRetentionService(db, cfg.LocationRetention, cfg.RetentionBatch)
+
+	switch args[0] {
+	case "run":
+		if cfg.LocationRetention == 0 {
+			return errors.New("LOCATION_RETENTION_DAYS не задан, проверки хранятся бессрочно")
+		}
+		return retention.Prune(ctx, time.Now())
+	case "partition":
+		if err := retention.Partition(ctx, time.Now()); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, "location_checks секционирована по дням")
+		return nil
+	default:
+		return errors.New(retentionUsage)
+	}
+}
@@ -0,0 +1,247 @@
+// Package app собирает сервис из слоёв и управляет его жизненным циклом: запуск, работа до сигнала и корректная остановка
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"RedCollar/api"
+	"RedCollar/internal/auth"
+	"RedCollar/internal/config"
+	"RedCollar/internal/delivery/http/middleware"
+	v1 "RedCollar/internal/delivery/http/v1"
+	"RedCollar/internal/i18n"
+	"RedCollar/internal/metrics"
+	"RedCollar/internal/migrator"
+	"RedCollar/internal/repository"
+	"RedCollar/internal/service"
+	"RedCollar/internal/tracing"
+	"RedCollar/internal/worker"
+)
+
+type App struct {
+	cfg *config.Config
+}
+
+func New(cfg *config.Config) *App {
+	return &App{cfg: cfg}
+}
+
+// Run поднимает зависимости, запускает http сервер и фоновые задачи и блокируется, пока не отменят ctx
+// (SIGINT/SIGTERM) или сервер не упадёт. После этого всё останавливается в порядке, обратном запуску:
+// http сервер перестаёт принимать запросы и дообрабатывает текущие, воркер дотправляет или возвращает в очередь взятый вебхук,
+// затем закрываются redis и postgres
+func (a *App) Run(ctx context.Context) error {
+	cfg := a.cfg
+	shutdownTimeout := time.Duration(cfg.ShutdownTimeout) * time.Second
+
+	//включаем экспорт трейсов, без OTEL_EXPORTER_OTLP_ENDPOINT трейсинг остаётся no-op
+	shutdownTracing, err := tracing.Init(ctx, cfg.OtlpEndpoint)
+	if err != nil {
+		return fmt.Errorf("не удалось настроить трейсинг: %w", err)
+	}
+	defer func() {
+		//трейсы закрываем последними, чтобы в них попали спаны остановки
+		tracingCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(tracingCtx); err != nil {
+			slog.Error("ошибка остановки трейсинга", slog.Any("error", err))
+		}
+	}()
+
+	//при включённом MIGRATE_ON_START схема обновляется до подключения пула, чтобы сервис не стартовал на старой схеме
+	if cfg.MigrateOnStart {
+		if err := migrate(cfg.PostgresDSN); err != nil {
+			return err
+		}
+	}
+
+	//устанавливаем подключение с PostgreSQL
+	db, err := repository.NewPostgresConnection(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться к БД(postgres): %w", err)
+	}
+	defer func() {
+		db.Close()
+		slog.Info("подключение к postgres закрыто")
+	}()
+
+	//устанавливаем подключение с Redis
+	rdb, err := repository.RedisConnection(ctx, cfg.RedisAddr)
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться к БД(redis): %w", err)
+	}
+	//defer выполняются в обратном порядке, поэтому redis закрывается раньше postgres
+	defer func() {
+		if err := rdb.Close(); err != nil {
+			slog.Error("ошибка закрытия redis", slog.Any("error", err))
+			return
+		}
+		slog.Info("подключение к redis закрыто")
+	}()
+
+	//регистрируем метрики, которые читаются из postgres и redis в момент сбора
+	metrics.RegisterPgxPool(db.Stat)
+	metrics.RegisterQueueDepth(rdb.QueueLength)
+
+	//инициализируем сервис
+	//в режиме приватности проверки сохраняются под псевдонимом пользователя и с округлёнными координатами
+	var privacy *service.Privacy
+	if cfg.PrivacyMode {
+		privacy = service.NewPrivacy(cfg.PrivacyKey, cfg.PrivacyDecimals)
+	}
+	serv := service.NewIncidentService(db, rdb, cfg.WarningZone, cfg.CacheTTL, cfg.StatsRetention, cfg.LiveRetention, privacy)
+	//выгрузка и удаление данных пользователя по его запросу, через него же воркер сохраняет доставки вебхуков
+	userData := service.NewUserDataService(db, rdb, privacy)
+
+	//организации нужны на каждый запрос и каждый вебхук, поэтому читаются через кэш в памяти
+	tenants := service.NewTenantService(db)
+
+	//инициализируем HTTP клиента и воркера, таймаут доставки воркер задаёт на каждую попытку,
+	//потому что у организации он может быть свой
+	client := service.NewHTTPClient(0)
+	w := worker.NewWebhookWorker(rdb, tenants, userData, client, cfg.WebhookUrl, cfg.WebhookRetries, time.Duration(cfg.WebhookTimeout)*time.Second, shutdownTimeout)
+	scheduler := worker.NewIncidentScheduler(serv, cfg.ScheduleTick)
+
+	//readiness проверяет postgres, redis, очередь вебхуков и heartbeat воркера
+	health := service.NewHealthService(db, rdb, rdb, w.LastHeartbeat, cfg.HealthTimeout, cfg.HeartbeatAge)
+	//API ключи хранятся в postgres, ключ из API_KEY работает как корневой со всеми правами
+	keys := service.NewAPIKeyService(db, cfg.ApiKey)
+	//журнал изменяющих действий операторов, записи только добавляются, поэтому пользователь в нём
+	//всегда хранится псевдонимом, даже без режима приватности
+	audit := service.NewAuditService(db, service.NewPrivacy(cfg.PrivacyKey, cfg.PrivacyDecimals))
+	jwtPolicy, err := a.jwtPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	ratePolicy, err := middleware.ParseRatePolicy(cfg.RateLimitDefault, cfg.RateLimits)
+	if err != nil {
+		return fmt.Errorf("некорректные лимиты запросов: %w", err)
+	}
+	lang, _ := i18n.ParseLang(cfg.Language) //язык уже проверен при валидации конфига
+	//спецификация встроена в бинарник, по ней проверяются запросы и она же отдаётся на /api/v1/openapi.json
+	spec, err := api.Load()
+	if err != nil {
+		return err
+	}
+	h := v1.NewHandler(serv, health, keys, tenants, audit, userData, jwtPolicy, middleware.MiddlewareRateLimit(rdb, ratePolicy), cfg.StatsTime, lang, spec, cfg.TrustedProxies)
+	router, err := h.Router()
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              ":" + cfg.AppPort,
+		Handler:           router,
+		ReadHeaderTimeout: time.Duration(cfg.HTTPReadTimeout) * time.Second,
+		ReadTimeout:       time.Duration(cfg.HTTPReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.HTTPWriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.HTTPIdleTimeout) * time.Second,
+	}
+
+	//фоновые задачи останавливаются и по сигналу, и при падении сервера.
+	//У них нет Accept-Language, поэтому вебхуки планировщика пишутся на языке по умолчанию
+	bgCtx, stopBackground := context.WithCancel(i18n.WithLang(ctx, lang))
+	defer stopBackground()
+
+	var background sync.WaitGroup
+	background.Go(func() { w.Run(bgCtx) })
+	//планировщик включает и выключает инциденты по valid_from/valid_until
+	background.Go(func() { scheduler.Run(bgCtx) })
+	//секции location_checks создаются заранее всегда, а при заданном сроке хранения старые проверки
+	//ещё и сворачиваются в почасовые итоги и удаляются
+	retention := worker.NewRetentionJob(service.NewRetentionService(db, cfg.LocationRetention, cfg.RetentionBatch), cfg.RetentionInterval)
+	background.Go(func() { retention.Run(bgCtx) })
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("сервер запущен", slog.String("port", cfg.AppPort))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	//ждём сигнала остановки или ошибки сервера
+	var runErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("получен сигнал остановки")
+	case err := <-serverErr:
+		if err != nil {
+			runErr = fmt.Errorf("ошибка запуска сервера: %w", err)
+		}
+	}
+
+	//на остановку всех этапов отводится общий shutdownTimeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	//перестаём принимать новые запросы и ждём завершения текущих
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("http сервер не успел завершить запросы", slog.Any("error", err))
+	} else {
+		slog.Info("http сервер остановлен")
+	}
+
+	//останавливаем воркер и планировщик: начатая попытка доставки вебхука получает не больше shutdownTimeout,
+	//а вебхук, который не успели доставить, возвращается в очередь
+	stopBackground()
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		slog.Info("фоновые задачи остановлены")
+	case <-shutdownCtx.Done():
+		slog.Error("фоновые задачи не успели остановиться")
+	}
+
+	return runErr
+}
+
+// jwtPolicy собирает режимы проверки токенов пользователей, ключи портала загружаются только если проверка включена
+func (a *App) jwtPolicy(ctx context.Context) (middleware.JWTPolicy, error) {
+	cfg := a.cfg
+	policy, err := middleware.ParseJWTPolicy(nil, cfg.JWTDefaultMode, cfg.JWTRouteModes)
+	if err != nil || !policy.Enabled() {
+		return policy, err
+	}
+	verifier, err := auth.NewVerifier(ctx, auth.JWTConfig{
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Secret:   cfg.JWTSecret,
+		JWKSFile: cfg.JWKSFile,
+		JWKSURL:  cfg.JWKSURL,
+	})
+	if err != nil {
+		return middleware.JWTPolicy{}, fmt.Errorf("не удалось настроить проверку JWT: %w", err)
+	}
+	policy.Verifier = verifier
+	return policy, nil
+}
+
+// migrate применяет новые миграции, параллельно стартующие реплики ждут друг друга на advisory lock
+func migrate(dsn string) error {
+	m, err := migrator.New(dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil {
+		return err
+	}
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	slog.Info("схема БД актуальна", slog.Uint64("version", uint64(status.Version)))
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package auth проверяет JWT конечных пользователей, выпущенные порталом (Django)
+package auth
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken - токен не прошёл проверку (подпись, срок, издатель, аудитория или нет subject)
+var ErrInvalidToken = errors.New("невалидный токен")
+
+// leeway - допустимое расхождение часов сервиса и портала
+const leeway = 30 * time.Second
+
+// JWTConfig - настройки проверки токенов. Должен быть задан хотя бы один источник ключей:
+// секрет для HS256 или JWKS (файл или URL) для RS256
+type JWTConfig struct {
+	Issuer   string
+	Audience string
+	Secret   string //общий секрет HS256
+	JWKSFile string //локальный файл JWKS с публичными ключами RS256
+	JWKSURL  string //URL JWKS портала, ключи периодически перечитываются
+}
+
+// Verifier проверяет подпись и клеймы токена и отдаёт subject - ID пользователя и его организацию
+type Verifier struct {
+	parser *jwt.Parser
+	secret []byte
+	jwks   jwt.Keyfunc
+}
+
+// NewVerifier собирает проверку из конфига, ctx ограничивает фоновое обновление JWKS по URL
+func NewVerifier(ctx context.Context, cfg JWTConfig) (*Verifier, error) {
+	v := &Verifier{}
+	methods := make([]string, 0, 2)
+	if cfg.Secret != "" {
+		v.secret = []byte(cfg.Secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	switch {
+	case cfg.JWKSFile != "":
+		raw, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения JWKS: %w", err)
+		}
+		k, err := keyfunc.NewJWKSetJSON(raw)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный JWKS: %w", err)
+		}
+		v.jwks = k.Keyfunc
+	case cfg.JWKSURL != "":
+		k, err := keyfunc.NewDefaultCtx(ctx, []string{cfg.JWKSURL})
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки JWKS: %w", err)
+		}
+		v.jwks = k.Keyfunc
+	}
+	if v.jwks != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("для проверки JWT нужен секрет HS256 или JWKS для RS256")
+	}
+
+	//алгоритм берётся из заголовка токена, поэтому явно ограничиваем допустимые, иначе возможна подмена alg
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(options...)
+	return v, nil
+}
+
+// tenantClaim - необязательный claim со slug организации пользователя
+const tenantClaim = "tenant"
+
+// Verify проверяет токен и отдаёт его subject и организацию
+func (v *Verifier) Verify(token string) (domain.UserClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return domain.UserClaims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return domain.UserClaims{}, fmt.Errorf("%w: в токене нет sub", ErrInvalidToken)
+	}
+	tenant, ok := claims[tenantClaim].(string)
+	if _, present := claims[tenantClaim]; present && !ok {
+		return domain.UserClaims{}, fmt.Errorf("%w: claim %s должен быть строкой", ErrInvalidToken, tenantClaim)
+	}
+	return domain.UserClaims{Subject: subject, Tenant: tenant}, nil
+}
+
+// key выбирает ключ проверки по алгоритму токена
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return v.jwks(token)
+	default:
+		return nil, fmt.Errorf("алгоритм %s не поддерживается", token.Method.Alg())
+	}
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testSecret   = "portal-hs256-secret"
+	testIssuer   = "https://portal.example.com"
+	testAudience = "redcollar"
+	testKeyID    = "portal-1"
+)
+
+// writeJWKS сохраняет публичный ключ в файл JWKS, как его отдаёт портал
+func writeJWKS(t *testing.T, key *rsa.PublicKey) string {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": testKeyID,
+		"alg": "RS256",
+		"use": "sig",
+		"n":   b64(key.N.Bytes()),
+		"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	raw, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// validClaims - клеймы токена, который проходит все проверки
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "user-42",
+		"iss": testIssuer,
+		"aud": testAudience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: must(x509.MarshalPKIXPublicKey(&rsaKey.PublicKey))})
+	jwksFile := writeJWKS(t, &rsaKey.PublicKey)
+
+	verifier, err := NewVerifier(context.Background(), JWTConfig{
+		Issuer: testIssuer, Audience: testAudience, Secret: testSecret, JWKSFile: jwksFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//без секрета HS256 токены с alg HS256 не принимаются вовсе
+	jwksOnly, err := NewVerifier(context.Background(), JWTConfig{Issuer: testIssuer, Audience: testAudience, JWKSFile: jwksFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs256 := func(claims jwt.MapClaims, secret []byte) string {
+		return must(jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret))
+	}
+	rs256 := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = testKeyID
+		return must(token.SignedString(rsaKey))
+	}
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := validClaims()
+		change(claims)
+		return claims
+	}
+
+	cases := []struct {
+		name     string
+		verifier *Verifier
+		token    string
+		tenant   string
+		ok       bool
+	}{
+		{"HS256 с общим секретом", verifier, hs256(validClaims(), []byte(testSecret)), "", true},
+		{"RS256 с ключом из JWKS", verifier, rs256(validClaims()), "", true},
+		{"организация из токена", verifier, rs256(with(func(c jwt.MapClaims) { c["tenant"] = "alpha" })), "alpha", true},
+		{"HS256 подписан публичным ключом RSA", verifier, hs256(validClaims(), publicPEM), "", false},
+		{"HS256 без настроенного секрета", jwksOnly, hs256(validClaims(), publicPEM), "", false},
+		{"alg none", verifier, must(jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)), "", false},
+		{"чужой секрет", verifier, hs256(validClaims(), []byte("other-secret")), "", false},
+		{"нет exp", verifier, rs256(with(func(c jwt.MapClaims) { delete(c, "exp") })), "", false},
+		{"истёк", verifier, rs256(with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })), "", false},
+		{"нет sub", verifier, rs256(with(func(c jwt.MapClaims) { delete(c, "sub") })), "", false},
+		{"пустой sub", verifier, rs256(with(func(c jwt.MapClaims) { c["sub"] = "" })), "", false},
+		{"чужой издатель", verifier, rs256(with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" })), "", false},
+		{"чужая аудитория", verifier, rs256(with(func(c jwt.MapClaims) { c["aud"] = "other-service" })), "", false},
+		{"организация не строкой", verifier, rs256(with(func(c jwt.MapClaims) { c["tenant"] = 42 })), "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := tc.verifier.Verify(tc.token)
+			if !tc.ok {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("токен принят или ошибка не ErrInvalidToken: %+v %v", claims, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("валидный токен отклонён: %v", err)
+			}
+			if claims.Subject != "user-42" || claims.Tenant != tc.tenant {
+				t.Fatalf("неверные клеймы: %+v", claims)
+			}
+		})
+	}
+}
+
+func TestNewVerifierRequiresKeys(t *testing.T) {
+	if _, err := NewVerifier(context.Background(), JWTConfig{Issuer: testIssuer}); err == nil {
+		t.Fatal("проверка без секрета и JWKS собрана без ошибки")
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"RedCollar/internal/i18n"
+	"RedCollar/internal/logger"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
 
 	"github.com/caarlos0/env/v11"
@@ -16,19 +18,58 @@ type Config struct {
 	RedisAddr      string  `env:"REDIS_ADDR" envDefault:"localhost:6379"`
 	WarningZone    float64 `env:"WARNING_ZONE" envDefault:"500.0"`
 	StatsTime      int     `env:"STATS_TIME_WINDOW_MINUTES" envDefault:"1"`
+	StatsRetention int     `env:"STATS_HLL_RETENTION_HOURS" envDefault:"48"`
+	LiveRetention  int     `env:"LIVE_RETENTION_MINUTES" envDefault:"60"`
 	CacheTimeout   int     `env:"CACHE_UPDATE_TIMEOUT" envDefault:"2"`
 	CacheTTL       int     `env:"CACHE_TTL" envDefault:"10"`
 	WebhookUrl     string  `env:"WEBHOOK_URL" envDefault:"http://localhost/"`
 	WebhookRetries int     `env:"WEBHOOK_RETRIES" envDefault:"3"`
 	WebhookTimeout int     `env:"WEBHOOK_TIMEOUT" envDefault:"10"`
-	ApiKey         string  `env:"API_KEY,required"`
+	ScheduleTick   int     `env:"SCHEDULER_INTERVAL" envDefault:"30"`
+	ApiKey         string  `env:"API_KEY"` //корневой ключ со всеми правами, пустой - только ключи из базы
+	MigrateOnStart bool    `env:"MIGRATE_ON_START" envDefault:"false"`
+
+	//срок хранения сырых проверок координат, 0 - хранить бессрочно
+	LocationRetention int `env:"LOCATION_RETENTION_DAYS" envDefault:"0"`
+	RetentionInterval int `env:"RETENTION_INTERVAL_MINUTES" envDefault:"60"` //как часто запускать удаление
+	RetentionBatch    int `env:"RETENTION_BATCH_SIZE" envDefault:"5000"`     //сколько строк удалять одним запросом
+
+	//режим приватности сохраняемых проверок: псевдонимы пользователей и округлённые координаты
+	PrivacyMode     bool   `env:"PRIVACY_MODE" envDefault:"false"`
+	PrivacyKey      string `env:"PRIVACY_USER_ID_KEY"`                   //секрет HMAC для псевдонимов user_id
+	PrivacyDecimals int    `env:"PRIVACY_COORD_DECIMALS" envDefault:"3"` //знаков после запятой у сохраняемых координат
+
+	//проверка JWT конечных пользователей, выпущенных порталом
+	JWTDefaultMode string            `env:"JWT_DEFAULT_MODE" envDefault:"off"`      //off, optional или required
+	JWTRouteModes  map[string]string `env:"JWT_ROUTE_MODES" envKeyValSeparator:"="` //маршрут=режим через запятую
+	JWTIssuer      string            `env:"JWT_ISSUER"`                             //ожидаемый iss, пустой - не проверяется
+	JWTAudience    string            `env:"JWT_AUDIENCE"`                           //ожидаемый aud, пустой - не проверяется
+	JWTSecret      string            `env:"JWT_HS256_SECRET"`                       //секрет для HS256
+	JWKSFile       string            `env:"JWT_JWKS_FILE"`                          //JWKS для RS256 из файла
+	JWKSURL        string            `env:"JWT_JWKS_URL"`                           //JWKS для RS256 по URL
+
+	//лимиты частоты запросов в формате N/s|m|h[:burst]
+	RateLimitDefault string            `env:"RATE_LIMIT_DEFAULT"`                 //для маршрутов без своего лимита, пустой - без лимита
+	RateLimits       map[string]string `env:"RATE_LIMITS" envKeyValSeparator:"="` //маршрут=лимит через запятую
+	OtlpEndpoint     string            `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`        //пустой - трейсы не экспортируются
+	LogLevel         string            `env:"LOG_LEVEL" envDefault:"info"`
+	Language         string            `env:"DEFAULT_LANGUAGE" envDefault:"ru"` //язык ответов без Accept-Language и фоновых вебхуков
+	HealthTimeout    int               `env:"HEALTH_CHECK_TIMEOUT" envDefault:"2"`
+	//прокси и балансировщики (IP или CIDR через запятую), которым доверяем X-Forwarded-For, пустой - никому
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:","`
+	//таймауты http сервера и время на корректную остановку, в секундах
+	HTTPReadTimeout  int `env:"HTTP_READ_TIMEOUT" envDefault:"10"`
+	HTTPWriteTimeout int `env:"HTTP_WRITE_TIMEOUT" envDefault:"30"`
+	HTTPIdleTimeout  int `env:"HTTP_IDLE_TIMEOUT" envDefault:"120"`
+	ShutdownTimeout  int `env:"SHUTDOWN_TIMEOUT" envDefault:"30"`
+	HeartbeatAge     int `env:"HEALTH_WORKER_MAX_AGE" envDefault:"60"`
 }
 
 func Load() (*Config, error) {
 	//подгружаем переменные в environment block
 	//если не получилось прочитать - логируем и пробуем получить их из настроек докера
 	if err := godotenv.Load(); err != nil {
-		log.Println("Файл .env не найден, чтение системных переменных окружения...")
+		slog.Info("файл .env не найден, чтение системных переменных окружения")
 	}
 
 	//создаём переменную в которую будем записывать тело структуры
@@ -49,10 +90,6 @@ func Load() (*Config, error) {
 
 // validate содержит всю необходимую валидацию для переменных .env
 func (c *Config) validate() error {
-	if len(c.ApiKey) > 20 {
-		return errors.New("API_KEY слишком длинный (максимум 20 символов)")
-	}
-
 	parsedURL, err := url.Parse(c.WebhookUrl)
 	if err != nil {
 		return fmt.Errorf("некорректный формат WEBHOOK_URL: %w", err)
@@ -63,7 +100,70 @@ func (c *Config) validate() error {
 
 	if c.StatsTime < 1 || c.StatsTime > 10000 {
 		c.StatsTime = 1
-		log.Println("Предупреждение: StatsTime вне диапазона, установлено значение 1")
+		slog.Warn("STATS_TIME_WINDOW_MINUTES вне диапазона, установлено значение 1")
+	}
+
+	if c.ScheduleTick < 1 {
+		return errors.New("SCHEDULER_INTERVAL должен быть положительным числом")
+	}
+
+	if c.StatsRetention < 1 {
+		return errors.New("STATS_HLL_RETENTION_HOURS должен быть положительным числом")
+	}
+
+	if c.LiveRetention < 1 {
+		return errors.New("LIVE_RETENTION_MINUTES должен быть положительным числом")
+	}
+
+	if c.LocationRetention < 0 {
+		return errors.New("LOCATION_RETENTION_DAYS не может быть отрицательным")
+	}
+
+	if c.RetentionInterval < 1 || c.RetentionBatch < 1 {
+		return errors.New("RETENTION_INTERVAL_MINUTES и RETENTION_BATCH_SIZE должны быть положительными числами")
+	}
+
+	//ключ нужен и без PRIVACY_MODE: им подписывается пользователь в журнале действий, который нельзя изменить,
+	//а короткий ключ позволяет перебрать псевдонимы по известным ID пользователей
+	if len(c.PrivacyKey) < 32 {
+		return errors.New("задайте PRIVACY_USER_ID_KEY длиной не меньше 32 символов")
+	}
+	if c.PrivacyMode {
+		if c.PrivacyDecimals < 0 || c.PrivacyDecimals > 6 {
+			return errors.New("PRIVACY_COORD_DECIMALS должен быть в диапазоне от 0 до 6")
+		}
+	}
+
+	if c.HealthTimeout < 1 {
+		return errors.New("HEALTH_CHECK_TIMEOUT должен быть положительным числом")
+	}
+
+	if c.HTTPReadTimeout < 1 || c.HTTPWriteTimeout < 1 || c.HTTPIdleTimeout < 1 {
+		return errors.New("HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT и HTTP_IDLE_TIMEOUT должны быть положительными числами")
+	}
+
+	if c.ShutdownTimeout < 1 {
+		return errors.New("SHUTDOWN_TIMEOUT должен быть положительным числом")
+	}
+
+	if c.HeartbeatAge < 1 {
+		return errors.New("HEALTH_WORKER_MAX_AGE должен быть положительным числом")
+	}
+
+	jwtEnabled := c.JWTDefaultMode != "off"
+	for _, mode := range c.JWTRouteModes {
+		jwtEnabled = jwtEnabled || mode != "off"
+	}
+	if jwtEnabled && c.JWTSecret == "" && c.JWKSFile == "" && c.JWKSURL == "" {
+		return errors.New("для проверки JWT задайте JWT_HS256_SECRET, JWT_JWKS_FILE или JWT_JWKS_URL")
+	}
+
+	if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("некорректный LOG_LEVEL: %w", err)
+	}
+
+	if _, err := i18n.ParseLang(c.Language); err != nil {
+		return fmt.Errorf("некорректный DEFAULT_LANGUAGE: %w", err)
 	}
 
 	if c.WarningZone <= 0 {
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// AuditIncidentKey - ключ gin контекста, в который хендлер кладёт ID инцидента, если его нет в пути (например при создании)
+	AuditIncidentKey = "audit_incident_id"
+	// AuditTargetKey - ключ gin контекста для user_id, над данными которого выполнялось действие
+	AuditTargetKey = "audit_target"
+)
+
+// AuditRecorder сохраняет запись журнала действий
+type AuditRecorder interface {
+	Record(ctx context.Context, entry *domain.AuditEntry)
+}
+
+// MiddlewareAudit записывает в журнал каждый изменяющий запрос оператора: кто, что, над каким инцидентом и с каким результатом.
+// Ставится после MiddlewareAuth и MiddlewareTenant, но до RequireScope, чтобы в журнал попадали и отказы в доступе
+func MiddlewareAudit(recorder AuditRecorder) gin.HandlerFunc {
+	return audit(recorder, false)
+}
+
+// MiddlewareAuditAll записывает в журнал и читающие запросы, например выгрузку персональных данных пользователя
+func MiddlewareAuditAll(recorder AuditRecorder) gin.HandlerFunc {
+	return audit(recorder, true)
+}
+
+func audit(recorder AuditRecorder, reads bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if !reads {
+				c.Next()
+				return
+			}
+		}
+
+		//тело хэшируется по мере того, как его читает хендлер, и в память целиком не загружается
+		hash := sha256.New()
+		var tee io.Reader
+		if body := c.Request.Body; body != nil {
+			tee = io.TeeReader(body, hash)
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{tee, body}
+		}
+
+		c.Next()
+
+		//хендлер мог не дочитать тело (отказ в доступе, лишние байты после JSON), дочитываем остаток в хэш
+		if tee != nil {
+			_, _ = io.Copy(io.Discard, tee)
+		}
+
+		ctx := c.Request.Context()
+		entry := &domain.AuditEntry{
+			TenantID:   domain.TenantIDFromContext(ctx),
+			Actor:      domain.ActorFromContext(ctx),
+			Method:     c.Request.Method,
+			Route:      c.FullPath(),
+			IncidentID: auditIncidentID(c),
+			Target:     c.GetString(AuditTargetKey),
+			BodySHA256: hex.EncodeToString(hash.Sum(nil)),
+			IP:         c.ClientIP(),
+			Status:     c.Writer.Status(),
+			RequestID:  domain.RequestIDFromContext(ctx),
+		}
+		if key := domain.APIKeyFromContext(ctx); key != nil && key.ID != uuid.Nil {
+			entry.ActorKeyID = &key.ID
+		}
+		//запрос уже обработан, запись в журнал не должна сорваться из-за отключившегося клиента
+		recorder.Record(context.WithoutCancel(ctx), entry)
+	}
+}
+
+// auditIncidentID берёт ID инцидента из пути /incidents/:id или из контекста хендлера
+func auditIncidentID(c *gin.Context) *uuid.UUID {
+	raw := c.GetString(AuditIncidentKey)
+	if raw == "" && c.Param("id") != "" && isIncidentRoute(c.FullPath()) {
+		raw = c.Param("id")
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// isIncidentRoute - :id в пути означает инцидент только на маршрутах инцидентов, у ключей и организаций это их собственный ID
+func isIncidentRoute(route string) bool {
+	return strings.HasPrefix(route, "/api/v1/incidents/")
+}
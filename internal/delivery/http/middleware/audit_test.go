@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"RedCollar/internal/domain"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lastEntry запоминает последнюю запись журнала
+type lastEntry struct {
+	entry *domain.AuditEntry
+}
+
+func (l *lastEntry) Record(ctx context.Context, entry *domain.AuditEntry) {
+	l.entry = entry
+}
+
+// TestAuditHashesWholeBody - хэш считается по всему телу, даже если хендлер дочитал его не до конца
+func TestAuditHashesWholeBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := &lastEntry{}
+	var received int
+	router := gin.New()
+	router.POST("/incidents", MiddlewareAudit(recorder), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		received = len(body)
+		c.Status(http.StatusCreated)
+	})
+	router.POST("/partial", MiddlewareAudit(recorder), func(c *gin.Context) {
+		_, _ = io.ReadFull(c.Request.Body, make([]byte, 10))
+		c.Status(http.StatusForbidden)
+	})
+
+	prefix := bytes.Repeat([]byte("x"), 256<<10)
+	cases := []struct {
+		name   string
+		target string
+		body   []byte
+	}{
+		{"тело целиком", "/incidents", append(slices.Clone(prefix), 'a')},
+		{"тело с тем же началом", "/incidents", append(slices.Clone(prefix), 'b')},
+		{"хендлер прочитал только начало", "/partial", append(slices.Clone(prefix), 'c')},
+	}
+	seen := map[string]bool{}
+	for _, tc := range cases {
+		received = 0
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, tc.target, bytes.NewReader(tc.body)))
+
+		if tc.target == "/incidents" && received != len(tc.body) {
+			t.Fatalf("%s: хендлер получил %d байт тела из %d", tc.name, received, len(tc.body))
+		}
+		sum := sha256.Sum256(tc.body)
+		if recorder.entry == nil || recorder.entry.BodySHA256 != hex.EncodeToString(sum[:]) {
+			t.Fatalf("%s: в журнал записан не хэш всего тела: %+v", tc.name, recorder.entry)
+		}
+		if seen[recorder.entry.BodySHA256] {
+			t.Fatalf("%s: у разных тел одинаковый хэш", tc.name)
+		}
+		seen[recorder.entry.BodySHA256] = true
+	}
+}
@@ -1,32 +1,65 @@
 package middleware
 
 import (
-	"net/http"
+	"RedCollar/internal/delivery/http/problem"
+	"RedCollar/internal/domain"
+	"context"
+	"errors"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Authenticator проверяет API ключ из заголовка, domain.ErrInvalidAPIKey означает невалидный ключ
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*domain.APIKey, error)
+}
+
 // MiddlewareAuth отвечает за то, чтобы проверить валидность ключа в заголовке для выдачи доступа к роли оператора
-func MiddlewareAuth(apiKey string) gin.HandlerFunc {
+func MiddlewareAuth(auth Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		//получаем ключ из заголовка
 		headerKey := c.GetHeader("X-API-KEY")
 
 		//если ключ пустой - отдаём ошибку, обозначающую, что необходимо ввести ключ
 		if len(headerKey) < 1 {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"Ошибка": "укажите заголовок с валидным API ключом"})
-			c.Abort()
+			problem.Abort(c, domain.Unauthorized(domain.CodeAPIKeyRequired, "укажите заголовок с валидным API ключом"))
 			return
 		}
 
-		//если полученный ключ не соответствует ключу из конфигурации - отдаём ошибку
-		if headerKey != apiKey {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"Ошибка": "невалидный API ключ"})
-			c.Abort()
+		//ищем ключ, сравнение идёт в постоянное время, истёкшие и отозванные ключи не проходят
+		key, err := auth.Authenticate(c.Request.Context(), headerKey)
+		if errors.Is(err, domain.ErrInvalidAPIKey) {
+			problem.Abort(c, domain.Unauthorized(domain.CodeInvalidAPIKey, "невалидный API ключ"))
+			return
+		}
+		if err != nil {
+			problem.Abort(c, fmt.Errorf("ошибка проверки API ключа: %w", err))
 			return
 		}
 
-		//если полученный ключ прошел обе проверки - позволяем выполнить дальнейшую логику программы
+		//запоминаем, кто выполняет запрос, чтобы записать его в историю изменений инцидентов
+		//автор - имя ключа, если ключом пользуются несколько человек, имя можно уточнить необязательным заголовком
+		actor := key.Name
+		if operator := c.GetHeader("X-Operator"); operator != "" {
+			actor += ":" + operator
+		}
+		ctx := domain.WithAPIKey(c.Request.Context(), key)
+		c.Request = c.Request.WithContext(domain.WithActor(ctx, actor))
+
+		//если полученный ключ прошел проверку - позволяем выполнить дальнейшую логику программы
+		c.Next()
+	}
+}
+
+// RequireScope пропускает запрос, только если у ключа есть нужное право, ставится после MiddlewareAuth
+func RequireScope(scope domain.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := domain.APIKeyFromContext(c.Request.Context())
+		if key == nil || !key.HasScope(scope) {
+			problem.Abort(c, domain.Forbidden(domain.CodeMissingScope, "у API ключа нет права %s", scope))
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"RedCollar/internal/delivery/http/problem"
+	"RedCollar/internal/domain"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWTMode - нужен ли маршруту токен пользователя
+type JWTMode string
+
+const (
+	JWTOff      JWTMode = "off"      //токен не проверяется, user_id берётся из тела запроса
+	JWTOptional JWTMode = "optional" //если токен передан - он проверяется и user_id берётся из него
+	JWTRequired JWTMode = "required" //без валидного токена запрос отклоняется
+)
+
+func (m JWTMode) IsValid() bool {
+	switch m {
+	case JWTOff, JWTOptional, JWTRequired:
+		return true
+	}
+	return false
+}
+
+// TokenVerifier проверяет токен и отдаёт его subject и организацию
+type TokenVerifier interface {
+	Verify(token string) (domain.UserClaims, error)
+}
+
+// JWTPolicy - режим проверки токена по маршрутам (шаблон пути gin, например /api/v1/location/check)
+type JWTPolicy struct {
+	Verifier TokenVerifier //nil, если проверка везде выключена
+	Default  JWTMode
+	Routes   map[string]JWTMode
+}
+
+// ParseJWTPolicy проверяет режимы из конфига
+func ParseJWTPolicy(verifier TokenVerifier, defaultMode string, routes map[string]string) (JWTPolicy, error) {
+	policy := JWTPolicy{Verifier: verifier, Default: JWTMode(defaultMode), Routes: make(map[string]JWTMode, len(routes))}
+	if !policy.Default.IsValid() {
+		return JWTPolicy{}, fmt.Errorf("неизвестный режим JWT %q", defaultMode)
+	}
+	for route, raw := range routes {
+		mode := JWTMode(raw)
+		if !mode.IsValid() {
+			return JWTPolicy{}, fmt.Errorf("неизвестный режим JWT %q для маршрута %s", raw, route)
+		}
+		policy.Routes[route] = mode
+	}
+	return policy, nil
+}
+
+// Enabled - хотя бы одному маршруту нужна проверка токена
+func (p JWTPolicy) Enabled() bool {
+	if p.Default != JWTOff {
+		return true
+	}
+	for _, mode := range p.Routes {
+		if mode != JWTOff {
+			return true
+		}
+	}
+	return false
+}
+
+func (p JWTPolicy) modeFor(route string) JWTMode {
+	if mode, ok := p.Routes[route]; ok {
+		return mode
+	}
+	return p.Default
+}
+
+// MiddlewareJWT проверяет токен пользователя из заголовка Authorization: Bearer <token> в режиме,
+// заданном для маршрута, и кладёт его subject в контекст, а организацию из токена - для MiddlewareTenant
+func MiddlewareJWT(policy JWTPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mode := policy.modeFor(c.FullPath())
+		if mode == JWTOff || policy.Verifier == nil {
+			c.Next()
+			return
+		}
+
+		token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || token == "" {
+			if mode == JWTRequired {
+				problem.Abort(c, domain.Unauthorized(domain.CodeTokenRequired, "укажите заголовок Authorization с токеном пользователя"))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		claims, err := policy.Verifier.Verify(token)
+		if err != nil {
+			slog.InfoContext(c.Request.Context(), "токен пользователя отклонён", slog.Any("error", err))
+			problem.Abort(c, domain.Unauthorized(domain.CodeInvalidToken, "невалидный токен пользователя").Wrap(err))
+			return
+		}
+		c.Request = c.Request.WithContext(domain.WithSubject(c.Request.Context(), claims.Subject))
+		if claims.Tenant != "" {
+			c.Set(tokenTenantKey, claims.Tenant)
+		}
+		c.Next()
+	}
+}
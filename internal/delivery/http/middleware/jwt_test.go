@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"RedCollar/internal/domain"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubVerifier принимает только токен "good"
+type stubVerifier struct{}
+
+func (stubVerifier) Verify(token string) (domain.UserClaims, error) {
+	if token != "good" {
+		return domain.UserClaims{}, errors.New("невалидный токен")
+	}
+	return domain.UserClaims{Subject: "user-42", Tenant: "alpha"}, nil
+}
+
+func TestMiddlewareJWTModes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy := JWTPolicy{
+		Verifier: stubVerifier{},
+		Default:  JWTOff,
+		Routes:   map[string]JWTMode{"/optional": JWTOptional, "/required": JWTRequired},
+	}
+	router := gin.New()
+	for _, route := range []string{"/off", "/optional", "/required"} {
+		router.GET(route, MiddlewareJWT(policy), func(c *gin.Context) {
+			c.String(http.StatusOK, domain.SubjectFromContext(c.Request.Context())+"|"+c.GetString(tokenTenantKey))
+		})
+	}
+
+	cases := []struct {
+		name   string
+		route  string
+		header string
+		status int
+		body   string //subject|организация для 200, код ошибки для остальных
+	}{
+		{"off без токена", "/off", "", http.StatusOK, "|"},
+		{"off не проверяет токен", "/off", "Bearer bad", http.StatusOK, "|"},
+		{"optional без токена", "/optional", "", http.StatusOK, "|"},
+		{"optional с токеном", "/optional", "Bearer good", http.StatusOK, "user-42|alpha"},
+		{"optional с невалидным токеном", "/optional", "Bearer bad", http.StatusUnauthorized, domain.CodeInvalidToken},
+		{"required без токена", "/required", "", http.StatusUnauthorized, domain.CodeTokenRequired},
+		{"required без Bearer", "/required", "good", http.StatusUnauthorized, domain.CodeTokenRequired},
+		{"required с токеном", "/required", "Bearer good", http.StatusOK, "user-42|alpha"},
+		{"required с невалидным токеном", "/required", "Bearer bad", http.StatusUnauthorized, domain.CodeInvalidToken},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.route, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("статус %d, ожидали %d: %s", rec.Code, tc.status, rec.Body)
+			}
+			if tc.status == http.StatusOK && rec.Body.String() != tc.body ||
+				tc.status != http.StatusOK && !strings.Contains(rec.Body.String(), `"`+tc.body+`"`) {
+				t.Fatalf("ответ %s, ожидали %s", rec.Body, tc.body)
+			}
+		})
+	}
+}
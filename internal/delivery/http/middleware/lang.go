@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"RedCollar/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MiddlewareLanguage выбирает язык ответа по заголовку Accept-Language и кладёт его в контекст:
+// на нём отдаются ошибки и пишутся тексты вебхуков, которые появились из-за запроса
+func MiddlewareLanguage(def i18n.Lang) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.Negotiate(c.GetHeader("Accept-Language"), def)
+		c.Request = c.Request.WithContext(i18n.WithLang(c.Request.Context(), lang))
+		c.Header("Content-Language", string(lang))
+		c.Header("Vary", "Accept-Language")
+		c.Next()
+	}
+}
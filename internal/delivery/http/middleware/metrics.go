@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"RedCollar/internal/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MiddlewareMetrics считает количество и время обработки запросов по маршрутам
+func MiddlewareMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		//используем шаблон маршрута (/incidents/:id), а не реальный путь, чтобы не плодить лейблы на каждый id
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"RedCollar/internal/delivery/http/problem"
+	"RedCollar/internal/domain"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+// specParamRe - параметр в пути спецификации: /incidents/{id} соответствует маршруту gin /incidents/:id
+var specParamRe = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// openAPIRoutes раскладывает операции спецификации по ключу "МЕТОД шаблон маршрута gin" с учётом пути сервера (/api/v1)
+func openAPIRoutes(doc *openapi3.T) map[string]*routers.Route {
+	//ошибку отдаёт только невалидный URL сервера, тогда ни один маршрут не найдётся и UndocumentedRoutes это покажет
+	base, _ := doc.Servers.BasePath()
+	base = strings.TrimSuffix(base, "/")
+	routes := make(map[string]*routers.Route)
+	for path, item := range doc.Paths.Map() {
+		ginPath := base + specParamRe.ReplaceAllString(path, ":$1")
+		for method, operation := range item.Operations() {
+			routes[method+" "+ginPath] = &routers.Route{
+				Spec:      doc,
+				Path:      path,
+				PathItem:  item,
+				Method:    method,
+				Operation: operation,
+			}
+		}
+	}
+	return routes
+}
+
+// UndocumentedRoutes отдаёт маршруты API, для которых в спецификации нет операции.
+// Маршруты вне пути сервера (например /metrics) не проверяются
+func UndocumentedRoutes(doc *openapi3.T, registered gin.RoutesInfo) []string {
+	base, _ := doc.Servers.BasePath()
+	base = strings.TrimSuffix(base, "/")
+	routes := openAPIRoutes(doc)
+	var missing []string
+	for _, r := range registered {
+		if r.Path != base && !strings.HasPrefix(r.Path, base+"/") {
+			continue
+		}
+		if _, ok := routes[r.Method+" "+r.Path]; !ok {
+			missing = append(missing, r.Method+" "+r.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// UnimplementedOperations отдаёт операции спецификации, для которых не зарегистрирован маршрут
+func UnimplementedOperations(doc *openapi3.T, registered gin.RoutesInfo) []string {
+	known := make(map[string]bool, len(registered))
+	for _, r := range registered {
+		known[r.Method+" "+r.Path] = true
+	}
+	var missing []string
+	for key := range openAPIRoutes(doc) {
+		if !known[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// MiddlewareOpenAPI проверяет параметры и тело запроса по спецификации до того, как запрос дойдёт до обработчика.
+// Операция ищется по шаблону маршрута, поэтому middleware ставится в группы маршрутов после аутентификации и лимита запросов.
+// Ключи, токены и права проверяют свои middleware, здесь схемы безопасности не проверяются,
+// а значения по умолчанию не подставляются: их по-прежнему выставляют обработчики
+func MiddlewareOpenAPI(doc *openapi3.T) gin.HandlerFunc {
+	routes := openAPIRoutes(doc)
+	options := &openapi3filter.Options{
+		AuthenticationFunc:         openapi3filter.NoopAuthenticationFunc,
+		ExcludeReadOnlyValidations: true, //инцидент из ответа можно отправить обратно в PUT вместе с id и created_at
+		SkipSettingDefaults:        true,
+	}
+	return func(c *gin.Context) {
+		route, ok := routes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		//ShouldBindJSON разбирает тело и без заголовка, поэтому клиенты часто его не присылают
+		if c.Request.ContentLength != 0 && c.GetHeader("Content-Type") == "" {
+			c.Request.Header.Set("Content-Type", "application/json")
+		}
+
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			problem.Abort(c, specError(c.Request, err))
+			return
+		}
+		c.Next()
+	}
+}
+
+// specError переводит ошибку проверки по спецификации в ошибку домена, текст валидатора остаётся в логах
+func specError(req *http.Request, err error) error {
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		return fmt.Errorf("проверка запроса по спецификации: %w", err)
+	}
+	var schemaErr *openapi3.SchemaError
+	isSchema := errors.As(err, &schemaErr)
+
+	if p := reqErr.Parameter; p != nil {
+		//значение разобрано, но не подходит по схеме (enum, диапазон) - 422, не разобрано вовсе - 400
+		if isSchema {
+			return domain.Invalid(domain.CodeInvalidParameter, "параметр %s не соответствует спецификации API", p.Name).OnField(p.Name).Wrap(err)
+		}
+		return domain.BadRequest(domain.CodeInvalidParameter, "параметр %s не соответствует спецификации API", p.Name).OnField(p.Name).Wrap(err)
+	}
+
+	if body := reqErr.RequestBody; body != nil && reqErr.Err == nil && body.Content.Get(req.Header.Get("Content-Type")) == nil {
+		types := make([]string, 0, len(body.Content))
+		for t := range body.Content {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return domain.BadRequest(domain.CodeUnsupportedMedia, "тело запроса должно быть в формате %s", strings.Join(types, ", ")).Wrap(err)
+	}
+	if isSchema {
+		if field := strings.Join(schemaErr.JSONPointer(), "."); field != "" {
+			return domain.Invalid(domain.CodeSchemaViolation, "поле %s не соответствует спецификации API", field).OnField(field).Wrap(err)
+		}
+	}
+	return domain.BadRequest(domain.CodeInvalidBody, "невалидное тело запроса").Wrap(err)
+}
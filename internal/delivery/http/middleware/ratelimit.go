@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"RedCollar/internal/delivery/http/problem"
+	"RedCollar/internal/domain"
+	"RedCollar/internal/metrics"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter берёт токен из корзины по ключу
+type RateLimiter interface {
+	TakeToken(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error)
+}
+
+// RatePolicy - лимиты по маршрутам (шаблон пути gin), для остальных маршрутов действует Default
+type RatePolicy struct {
+	Default *domain.RateLimit //nil - маршруты без своего лимита не ограничиваются
+	Routes  map[string]domain.RateLimit
+}
+
+// ParseRatePolicy разбирает лимиты из конфига в формате N/период[:burst], например 60/m или 10/s:20
+func ParseRatePolicy(defaultLimit string, routes map[string]string) (RatePolicy, error) {
+	policy := RatePolicy{Routes: make(map[string]domain.RateLimit, len(routes))}
+	if defaultLimit != "" {
+		limit, err := parseRateLimit(defaultLimit)
+		if err != nil {
+			return RatePolicy{}, err
+		}
+		policy.Default = &limit
+	}
+	for route, raw := range routes {
+		limit, err := parseRateLimit(raw)
+		if err != nil {
+			return RatePolicy{}, fmt.Errorf("маршрут %s: %w", route, err)
+		}
+		policy.Routes[route] = limit
+	}
+	return policy, nil
+}
+
+var ratePeriods = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+
+func parseRateLimit(raw string) (domain.RateLimit, error) {
+	invalid := fmt.Errorf("некорректный лимит %q, ожидается N/s|m|h[:burst]", raw)
+	spec, rawBurst, hasBurst := strings.Cut(raw, ":")
+	rawRequests, rawPeriod, ok := strings.Cut(spec, "/")
+	if !ok {
+		return domain.RateLimit{}, invalid
+	}
+	requests, err := strconv.Atoi(rawRequests)
+	period, known := ratePeriods[rawPeriod]
+	if err != nil || requests < 1 || !known {
+		return domain.RateLimit{}, invalid
+	}
+	limit := domain.RateLimit{Requests: requests, Period: period}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(rawBurst); err != nil || limit.Burst < 1 {
+			return domain.RateLimit{}, invalid
+		}
+	}
+	return limit, nil
+}
+
+func (p RatePolicy) limitFor(route string) (domain.RateLimit, bool) {
+	if limit, ok := p.Routes[route]; ok {
+		return limit, true
+	}
+	if p.Default != nil {
+		return *p.Default, true
+	}
+	return domain.RateLimit{}, false
+}
+
+// MiddlewareRateLimit ограничивает частоту запросов по маршруту. Клиент определяется по API ключу,
+// затем по пользователю из JWT и в последнюю очередь по IP, поэтому middleware ставится после аутентификации.
+// Отвечает заголовками RateLimit-Limit/Remaining/Reset, а при превышении - 429 с Retry-After
+func MiddlewareRateLimit(limiter RateLimiter, policy RatePolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		limit, ok := policy.limitFor(route)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		result, err := limiter.TakeToken(ctx, route+":"+clientKey(c), limit)
+		if err != nil {
+			//недоступный redis не должен класть весь API, поэтому пропускаем запрос без лимита
+			slog.WarnContext(ctx, "rate limiter недоступен", slog.Any("error", err))
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Capacity()))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			metrics.RateLimited.WithLabelValues(route).Inc()
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			problem.Abort(c, domain.TooManyRequests(domain.CodeRateLimited, "слишком много запросов, повторите позже"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKey определяет, чью корзину расходует запрос
+func clientKey(c *gin.Context) string {
+	ctx := c.Request.Context()
+	if key := domain.APIKeyFromContext(ctx); key != nil {
+		return "key:" + key.ID.String()
+	}
+	if subject := domain.SubjectFromContext(ctx); subject != "" {
+		return "user:" + subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// ceilSeconds - заголовки RateLimit-* и Retry-After задаются в целых секундах, округляем вверх
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"RedCollar/internal/domain"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader - заголовок с ID запроса, его же получает получатель вебхука
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину ID, пришедшего от клиента, чтобы он не раздувал логи
+const maxRequestIDLength = 128
+
+// MiddlewareRequestID берёт ID запроса из заголовка X-Request-ID (или генерирует новый),
+// кладёт его в контекст и возвращает клиенту в ответе
+func MiddlewareRequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+		c.Request = c.Request.WithContext(domain.WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// MiddlewareLogger пишет по одной записи на каждый обработанный запрос вместо текстового логгера gin
+func MiddlewareLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "http запрос", attrs...)
+	}
+}
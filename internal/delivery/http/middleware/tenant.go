@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"RedCollar/internal/delivery/http/problem"
+	"RedCollar/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// tokenTenantKey - ключ gin контекста, в который MiddlewareJWT кладёт slug организации из токена
+const tokenTenantKey = "token_tenant"
+
+// TenantHeader - заголовок со slug организации для корневого ключа и публичных запросов без токена
+const TenantHeader = "X-Tenant"
+
+// TenantResolver отдаёт организацию по ID или slug, domain.ErrTenantNotFound - организации нет
+type TenantResolver interface {
+	Get(ctx context.Context, id uuid.UUID) (*domain.Tenant, error)
+	GetBySlug(ctx context.Context, slug string) (*domain.Tenant, error)
+}
+
+// MiddlewareTenant определяет организацию запроса и кладёт её в контекст, по ней ограничиваются все данные.
+// Ставится после MiddlewareAuth или MiddlewareJWT. Организация берётся:
+//   - у API ключа организации - из самого ключа, X-Tenant может только совпадать с ней;
+//   - у корневого ключа - из X-Tenant;
+//   - у пользователя - из claim tenant проверенного токена, иначе из X-Tenant;
+//
+// а если её нигде нет - используется организация по умолчанию
+func MiddlewareTenant(tenants TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		header := c.GetHeader(TenantHeader)
+
+		var tenant *domain.Tenant
+		var err error
+		key := domain.APIKeyFromContext(ctx)
+		switch {
+		case key != nil && key.TenantID != uuid.Nil:
+			tenant, err = tenants.Get(ctx, key.TenantID)
+			if err == nil && header != "" && header != tenant.Slug {
+				problem.Abort(c, domain.Forbidden(domain.CodeTenantMismatch, "API ключ не относится к организации %s", header))
+				return
+			}
+		case key == nil && c.GetString(tokenTenantKey) != "":
+			//организация из подписанного токена важнее заголовка, который клиент может подставить сам
+			tenant, err = tenants.GetBySlug(ctx, c.GetString(tokenTenantKey))
+		case header != "":
+			tenant, err = tenants.GetBySlug(ctx, header)
+		default:
+			tenant, err = tenants.Get(ctx, domain.DefaultTenantID)
+		}
+		if errors.Is(err, domain.ErrTenantNotFound) {
+			//организацию назвал сам клиент, поэтому это ошибка запроса, а не отсутствующий ресурс
+			problem.Abort(c, domain.BadRequest(domain.CodeTenantNotFound, "организация не найдена"))
+			return
+		}
+		if err != nil {
+			problem.Abort(c, fmt.Errorf("ошибка определения организации: %w", err))
+			return
+		}
+
+		c.Request = c.Request.WithContext(domain.WithTenant(ctx, tenant))
+		c.Next()
+	}
+}
@@ -0,0 +1,85 @@
+package problem
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/i18n"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType - тип ответа с ошибкой по RFC 7807
+const ContentType = "application/problem+json"
+
+// Problem - тело ответа с ошибкой по RFC 7807, дополненное машиночитаемым кодом и ошибками полей
+type Problem struct {
+	Type      string              `json:"type"`                 //about:blank - смысл ошибки передают status и code
+	Title     string              `json:"title"`                //Текст HTTP статуса
+	Status    int                 `json:"status"`               //HTTP статус
+	Detail    string              `json:"detail,omitempty"`     //Описание ошибки для человека на языке из Accept-Language
+	Code      string              `json:"code"`                 //Машиночитаемый код ошибки
+	Errors    []domain.FieldError `json:"errors,omitempty"`     //Ошибки конкретных полей запроса
+	RequestID string              `json:"request_id,omitempty"` //ID запроса, по нему ошибку можно найти в логах
+}
+
+// statuses - какой HTTP статус отдаётся для каждого класса ошибок
+var statuses = map[domain.ErrorKind]int{
+	domain.KindBadRequest:      http.StatusBadRequest,
+	domain.KindValidation:      http.StatusUnprocessableEntity,
+	domain.KindNotFound:        http.StatusNotFound,
+	domain.KindConflict:        http.StatusConflict,
+	domain.KindUnauthorized:    http.StatusUnauthorized,
+	domain.KindForbidden:       http.StatusForbidden,
+	domain.KindTooManyRequests: http.StatusTooManyRequests,
+}
+
+// From собирает тело ответа из ошибки. Всё, что не является domain.Error, считается внутренней ошибкой:
+// её текст может содержать детали базы или redis, поэтому клиенту отдаётся только общий ответ
+func From(c *gin.Context, err error) Problem {
+	ctx := c.Request.Context()
+	lang := i18n.FromContext(ctx)
+	p := Problem{
+		Type:      "about:blank",
+		Status:    http.StatusInternalServerError,
+		Code:      domain.CodeInternal,
+		Detail:    localize(lang, domain.CodeInternal, nil, "внутренняя ошибка сервиса"),
+		RequestID: domain.RequestIDFromContext(ctx),
+	}
+	var e *domain.Error
+	if errors.As(err, &e) {
+		if status, ok := statuses[e.Kind]; ok {
+			p.Status = status
+			p.Code = e.Code
+			p.Detail = localize(lang, e.Code, e.Args, e.Message)
+			for _, f := range e.Fields {
+				f.Message = localize(lang, f.Code, f.Args, f.Message)
+				p.Errors = append(p.Errors, f)
+			}
+		}
+	}
+	p.Title = http.StatusText(p.Status)
+	return p
+}
+
+// localize отдаёт текст ошибки на языке клиента, для кода без перевода остаётся текст из сервиса
+func localize(lang i18n.Lang, code string, args []any, fallback string) string {
+	if text, ok := i18n.Text(lang, code, args...); ok {
+		return text
+	}
+	return fallback
+}
+
+// Write отвечает клиенту ошибкой, сама ошибка целиком попадает в лог запроса
+func Write(c *gin.Context, err error) {
+	_ = c.Error(err)
+	p := From(c, err)
+	c.Header("Content-Type", ContentType)
+	c.JSON(p.Status, p)
+}
+
+// Abort - Write для middleware: дальнейшие обработчики запроса не вызываются
+func Abort(c *gin.Context, err error) {
+	c.Abort()
+	Write(c, err)
+}
@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"RedCollar/internal/delivery/http/problem"
+	"RedCollar/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/v1/admin/keys
+func (h *Handler) IssueAPIKey(c *gin.Context) {
+	var req domain.IssueAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, errInvalidBody(err))
+		return
+	}
+
+	issued, err := h.keys.Issue(c.Request.Context(), req)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	//токен показывается только в этом ответе, в базе хранится лишь его хэш
+	c.JSON(201, issued)
+}
+
+// GET /api/v1/admin/keys
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.keys.List(c.Request.Context())
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(200, keys)
+}
+
+// DELETE /api/v1/admin/keys/:id
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id := c.Param("id")
+	err := h.keys.Revoke(c.Request.Context(), id)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	//ключ перестаёт работать сразу, запись остаётся в списке с revoked_at
+	c.JSON(200, gin.H{"id": id})
+}
@@ -1,13 +1,63 @@
 package v1
 
 import (
+	"RedCollar/internal/delivery/http/middleware"
+	"RedCollar/internal/delivery/http/problem"
 	"RedCollar/internal/domain"
 	"RedCollar/internal/service"
+	"context"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// errInvalidBody - тело запроса не разобрать как JSON нужной структуры, текст ошибки парсера остаётся в логах
+func errInvalidBody(err error) error {
+	return domain.BadRequest(domain.CodeInvalidBody, "невалидное тело запроса").Wrap(err)
+}
+
+// errNotNumber - query параметр должен быть целым числом
+func errNotNumber(param string) error {
+	return domain.BadRequest(domain.CodeQueryNotNumber, "%s должен быть числом", param).OnField(param)
+}
+
+// errNotTime - query параметр должен быть временем в RFC3339
+func errNotTime(param string) error {
+	return domain.BadRequest(domain.CodeQueryNotTime, "%s должен быть в формате RFC3339", param).OnField(param)
+}
+
+// parseFilter читает необязательные фильтры из query параметров:
+// ?category=fire,flood (или несколько ?category=...), ?min_severity=3 и ?status=active,resolved
+func parseFilter(c *gin.Context) (domain.IncidentFilter, error) {
+	var filter domain.IncidentFilter
+	for _, raw := range c.QueryArray("category") {
+		for _, category := range strings.Split(raw, ",") {
+			category = strings.TrimSpace(category)
+			if category != "" {
+				filter.Categories = append(filter.Categories, domain.IncidentCategory(category))
+			}
+		}
+	}
+	if raw := c.Query("min_severity"); raw != "" {
+		severity, err := strconv.Atoi(raw)
+		if err != nil {
+			return domain.IncidentFilter{}, errNotNumber("min_severity")
+		}
+		filter.MinSeverity = domain.Severity(severity)
+	}
+	for _, raw := range c.QueryArray("status") {
+		for _, status := range strings.Split(raw, ",") {
+			status = strings.TrimSpace(status)
+			if status != "" {
+				filter.Statuses = append(filter.Statuses, domain.IncidentStatus(status))
+			}
+		}
+	}
+	return filter, service.ValidateFilter(filter)
+}
+
 // POST /api/v1/location/check
 func (h *Handler) checkLocation(c *gin.Context) {
 	//Создаем переменную в которую будем записывать ответ
@@ -16,25 +66,33 @@ func (h *Handler) checkLocation(c *gin.Context) {
 	//Анмаршалим реквест в переменную
 	err := c.ShouldBindJSON(&request)
 	if err != nil {
-		c.JSON(400, gin.H{ //до вызова сервиса мы обрабатываем кейс когда ошибка возникает по вине пользователя
-			"Ошибка": "Невалидное тело запроса",
-		})
+		//до вызова сервиса мы обрабатываем кейс когда ошибка возникает по вине пользователя
+		problem.Write(c, errInvalidBody(err))
 		return
 	}
 
+	//если пользователь пришёл с токеном портала - доверяем только ему, user_id из тела игнорируем
+	if subject := domain.SubjectFromContext(c.Request.Context()); subject != "" {
+		request.UserID = subject
+	}
+
 	//DefaultQuery проверяет не пришёл ли нам query параметр
 	//если пришел - записываем в переменную
 	//если не пришел - ставим дефолт
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	//Когда у нас готовы все аргументы - вызываем метод сервиса
-	resp, err := h.service.CheckLocation(c.Request.Context(), request, limit, offset)
+	//читаем фильтры по категории и уровню опасности
+	filter, err := parseFilter(c)
 	if err != nil {
-		c.JSON(500, gin.H{ //после вызова сервиса когда мы уверены, что полученные данные валидные
-			"Ошибка": err.Error(), // отдаём на потенциальную ошибку статус код 500 и распаковываем ошибку
+		problem.Write(c, err)
+		return
+	}
 
-		})
+	//Когда у нас готовы все аргументы - вызываем метод сервиса
+	resp, err := h.service.CheckLocation(c.Request.Context(), request, limit, offset, filter)
+	if err != nil {
+		problem.Write(c, err)
 		return
 	}
 
@@ -42,33 +100,121 @@ func (h *Handler) checkLocation(c *gin.Context) {
 	c.JSON(200, resp)
 }
 
+// parseStatsQuery читает необязательные параметры статистики: ?from=...&to=... в RFC3339, ?bucket=minute|hour|day
+// и ?mode=approx|exact
+func parseStatsQuery(c *gin.Context) (domain.StatsQuery, error) {
+	var q domain.StatsQuery
+	var err error
+	if raw := c.Query("from"); raw != "" {
+		if q.From, err = time.Parse(time.RFC3339, raw); err != nil {
+			return q, errNotTime("from")
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if q.To, err = time.Parse(time.RFC3339, raw); err != nil {
+			return q, errNotTime("to")
+		}
+	}
+	q.Bucket = domain.StatsBucket(c.Query("bucket"))
+	q.Mode = domain.StatsMode(c.Query("mode"))
+	return q, nil
+}
+
 // GetStats реализует требования тз, отдавая статистику по зонам  по запросу GET /api/v1/incidents/stats
+// без параметров отдаётся статистика за последние STATS_TIME_WINDOW_MINUTES минут
 func (h *Handler) GetStats(c *gin.Context) {
-	//читать какие-то данные от пользователя нам не нужно, так что просто сразу же вызываем сервис
-	result, err := h.service.GetStats(c.Request.Context(), h.statsTime)
+	q, err := parseStatsQuery(c)
 	if err != nil {
-		c.JSON(500, gin.H{"Ошибка": err.Error()}) //обрабатываем единственный кейс когда у нас может что-то поломаться
+		problem.Write(c, err)
+		return
+	}
+
+	result, err := h.service.GetStats(c.Request.Context(), h.statsTime, q)
+	if err != nil {
+		problem.Write(c, err)
 		return
 	}
 	c.JSON(200, result) //и возвращаем полученный результат
 }
 
+// GET /api/v1/incidents/heatmap?from=..&to=..&precision=6
+func (h *Handler) GetHeatmap(c *gin.Context) {
+	//период читаем так же, как для статистики
+	q, err := parseStatsQuery(c)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	precision, err := strconv.Atoi(c.DefaultQuery("precision", "6"))
+	if err != nil {
+		problem.Write(c, errNotNumber("precision"))
+		return
+	}
+
+	result, err := h.service.GetHeatmap(c.Request.Context(), h.statsTime, q.From, q.To, precision)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(200, result)
+}
+
+// GET /api/v1/incidents/:id/users?max_age=300&count_only=true
+func (h *Handler) GetZoneUsers(c *gin.Context) {
+	id := c.Param("id")
+
+	//max_age - через сколько секунд без проверок позиция пользователя считается устаревшей
+	maxAge, err := strconv.Atoi(c.DefaultQuery("max_age", "300"))
+	if err != nil {
+		problem.Write(c, errNotNumber("max_age"))
+		return
+	}
+	countOnly := c.Query("count_only") == "true"
+
+	result, err := h.service.UsersInZone(c.Request.Context(), id, time.Duration(maxAge)*time.Second, countOnly)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+// GET /api/v1/incidents/:id/stats
+func (h *Handler) GetIncidentStats(c *gin.Context) {
+	id := c.Param("id")
+
+	q, err := parseStatsQuery(c)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+
+	result, err := h.service.GetIncidentStats(c.Request.Context(), id, h.statsTime, q)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
 // POST /api/v1/incidents
 func (h *Handler) CreateIncident(c *gin.Context) {
 	//создаем переменную в которую записываем полученные параметры инцидента
 	var input domain.Incident
 	if err := c.ShouldBindJSON(&input); err != nil { //если ошибка - отдаём ошибку
-		c.JSON(400, gin.H{"Ошибка": err.Error()})
+		problem.Write(c, errInvalidBody(err))
 		return
 	}
 
 	//вызываем сервис с переданной структурой
 	id, err := h.service.Create(c.Request.Context(), &input)
 	if err != nil {
-		c.JSON(500, gin.H{"Ошибка": err.Error()}) //если ошибка - ошибка
+		problem.Write(c, err)
 		return
 	}
-	c.JSON(200, gin.H{"id": id}) // если всё ок - отдаём ок и айди созданного инцидента
+	c.Set(middleware.AuditIncidentKey, id) //ID нового инцидента есть только в ответе, передаём его в журнал действий
+	c.JSON(200, gin.H{"id": id})           // если всё ок - отдаём ок и айди созданного инцидента
 }
 
 // GET /api/v1/incidents/:id
@@ -79,7 +225,7 @@ func (h *Handler) GetIncidentByID(c *gin.Context) {
 	//вызываем сервис с айди в аргументах
 	result, err := h.service.GetByID(c.Request.Context(), id)
 	if err != nil { //если ошибка - отдаём ошибку
-		c.JSON(500, gin.H{"Ошибка": err.Error()})
+		problem.Write(c, err)
 		return
 	}
 
@@ -95,7 +241,7 @@ func (h *Handler) DeleteIncident(c *gin.Context) {
 	//вызываем сервис с переданным id
 	err := h.service.Delete(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(500, gin.H{"Ошибка": err.Error()}) // если ошибка - отдаём ошибку
+		problem.Write(c, err)
 		return
 	}
 
@@ -111,12 +257,12 @@ func (h *Handler) UpdateIncident(c *gin.Context) {
 	//создаем структуру инцидента в которую записываем новые данные
 	var input domain.Incident
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"Ошибка": err.Error()}) //если ошибка - отдаём ошибку
+		problem.Write(c, errInvalidBody(err)) //если ошибка - отдаём ошибку
 		return
 	}
 	uuid, err := h.service.Update(c.Request.Context(), id, &input)
 	if err != nil {
-		c.JSON(500, gin.H{"Ошибка": err.Error()})
+		problem.Write(c, err)
 		return
 	}
 	c.JSON(200, gin.H{"UUID": uuid})
@@ -127,27 +273,90 @@ func (h *Handler) GetIncidents(c *gin.Context) {
 	//создаём структуру запроса, в которую будем записывать ответ
 	var request domain.LocationCheckRequest
 	if err := c.ShouldBindJSON(&request); err != nil { //кейс неверного формата запроса
-		c.JSON(400, gin.H{"ошибка": err.Error()})
+		problem.Write(c, errInvalidBody(err))
 		return
 	}
 	err := service.ValidateCoordinates(request.Latitude, request.Longitude)
 	if err != nil {
-		c.JSON(400, gin.H{"ошибка": err.Error()}) //кейс невалидных координат
+		problem.Write(c, err) //кейс невалидных координат
 		return
 	}
 	//получаем параметры пагинации
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	//получаем фильтры по категории и уровню опасности
+	filter, err := parseFilter(c)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
 	//передаем всё в аргументы метода сервиса
-	result, err := h.service.Get(c.Request.Context(), request.Latitude, request.Longitude, limit, offset)
+	result, err := h.service.Get(c.Request.Context(), request.Latitude, request.Longitude, limit, offset, filter)
 	if err != nil {
-		c.JSON(500, gin.H{"Ошибка": err.Error()}) // если ошибка - отдаём ошибку
+		problem.Write(c, err)
 		return
 	}
 	c.JSON(200, result) //если всё ок - отдаём ок и результат
 }
 
-// GET /api/v1/system/health
+// GET /api/v1/incidents/:id/history
+func (h *Handler) GetIncidentHistory(c *gin.Context) {
+	id := c.Param("id")
+
+	result, err := h.service.GetHistory(c.Request.Context(), id)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+// POST /api/v1/incidents/:id/history/:version/restore
+func (h *Handler) RestoreIncident(c *gin.Context) {
+	id := c.Param("id")
+
+	//номер версии берём из url, он должен быть положительным числом
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		problem.Write(c, domain.BadRequest(domain.CodeInvalidVersion, "невалидный номер версии").OnField("version"))
+		return
+	}
+
+	result, err := h.service.Restore(c.Request.Context(), id, version)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+// POST /api/v1/incidents/:id/{publish,resolve,reopen,archive}
+// ChangeStatus собирает хендлер перехода из метода сервиса, т.к. все переходы обрабатываются одинаково
+func (h *Handler) ChangeStatus(transition func(ctx context.Context, id string) (*domain.Incident, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		result, err := transition(c.Request.Context(), id)
+		if err != nil {
+			problem.Write(c, err)
+			return
+		}
+		c.JSON(200, result)
+	}
+}
+
+// GET /api/v1/system/health/live
+// liveness не проверяет зависимости: если упал postgres, перезапуск сервиса не поможет
 func (h *Handler) GetHealth(c *gin.Context) {
 	c.JSON(200, gin.H{"всё": "ок"})
 }
+
+// GET /api/v1/system/health/ready
+func (h *Handler) GetReadiness(c *gin.Context) {
+	report := h.health.Ready(c.Request.Context())
+	if report.Status != domain.HealthOK {
+		c.JSON(503, report)
+		return
+	}
+	c.JSON(200, report)
+}
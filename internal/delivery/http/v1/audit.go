@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"RedCollar/internal/delivery/http/problem"
+	"RedCollar/internal/domain"
+	"encoding/csv"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// auditCSVHeader - колонки выгрузки журнала, порядок совпадает с auditCSVRow
+var auditCSVHeader = []string{"id", "created_at", "actor_key_id", "actor", "method", "route", "incident_id", "target", "status", "body_sha256", "ip", "request_id"}
+
+func auditCSVRow(e *domain.AuditEntry) []string {
+	optional := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	return []string{
+		strconv.FormatInt(e.ID, 10), e.CreatedAt.Format(time.RFC3339), optional(e.ActorKeyID), e.Actor, e.Method, e.Route,
+		optional(e.IncidentID), e.Target, strconv.Itoa(e.Status), e.BodySHA256, e.IP, e.RequestID,
+	}
+}
+
+// parseAuditFilter читает фильтры журнала из query: actor_key_id, incident_id, target, route, method, result, from, to, limit, offset
+func parseAuditFilter(c *gin.Context) (domain.AuditFilter, error) {
+	filter := domain.AuditFilter{
+		Route:  c.Query("route"),
+		Method: c.Query("method"),
+		Result: domain.AuditResult(c.Query("result")),
+	}
+	if target := c.Query("target"); target != "" {
+		filter.Targets = []string{target}
+	}
+	parseID := func(name string) (*uuid.UUID, error) {
+		raw := c.Query(name)
+		if raw == "" {
+			return nil, nil
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, domain.BadRequest(domain.CodeInvalidID, "невалидный ID: %q", raw).OnField(name)
+		}
+		return &id, nil
+	}
+	var err error
+	if filter.ActorKeyID, err = parseID("actor_key_id"); err != nil {
+		return filter, err
+	}
+	if filter.IncidentID, err = parseID("incident_id"); err != nil {
+		return filter, err
+	}
+	if raw := c.Query("from"); raw != "" {
+		if filter.From, err = time.Parse(time.RFC3339, raw); err != nil {
+			return filter, errNotTime("from")
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if filter.To, err = time.Parse(time.RFC3339, raw); err != nil {
+			return filter, errNotTime("to")
+		}
+	}
+	if filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "0")); err != nil {
+		return filter, errNotNumber("limit")
+	}
+	if filter.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil {
+		return filter, errNotNumber("offset")
+	}
+	return filter, nil
+}
+
+// GET /api/v1/admin/audit
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	entries, err := h.audit.List(c.Request.Context(), filter)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(200, entries)
+}
+
+// GET /api/v1/admin/audit/export - тот же журнал в CSV, выгружаются все записи под фильтром
+func (h *Handler) ExportAuditLog(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="audit.csv"`)
+	c.Status(200)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(auditCSVHeader)
+	//ответ уже начат, поэтому ошибку посреди выгрузки можно только залогировать, клиент получит обрезанный файл
+	err = h.audit.Export(c.Request.Context(), filter, func(e *domain.AuditEntry) error {
+		return w.Write(auditCSVRow(e))
+	})
+	w.Flush()
+	if err != nil {
+		_ = c.Error(err)
+	}
+}
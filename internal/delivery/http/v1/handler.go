@@ -3,69 +3,241 @@ package v1
 import (
 	"RedCollar/internal/delivery/http/middleware"
 	"RedCollar/internal/domain"
+	"RedCollar/internal/i18n"
+	"RedCollar/internal/logger"
+	"RedCollar/internal/tracing"
 	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // Описываем, что хендлер ждет от сервиса
 type IncidentService interface {
-	CheckLocation(ctx context.Context, req domain.LocationCheckRequest, limit, offset int) (domain.LocationCheckResponse, error)
-	GetStats(ctx context.Context, statsTime int) ([]domain.StatisticResponse, error)
+	CheckLocation(ctx context.Context, req domain.LocationCheckRequest, limit, offset int, filter domain.IncidentFilter) (domain.LocationCheckResponse, error)
+	GetStats(ctx context.Context, statsTime int, q domain.StatsQuery) ([]domain.StatisticResponse, error)
+	GetIncidentStats(ctx context.Context, id string, statsTime int, q domain.StatsQuery) (domain.StatisticResponse, error)
+	GetHeatmap(ctx context.Context, statsTime int, from, to time.Time, precision int) (domain.FeatureCollection, error)
+	UsersInZone(ctx context.Context, id string, maxAge time.Duration, countOnly bool) (domain.ZoneUsersResponse, error)
 	Create(ctx context.Context, i *domain.Incident) (string, error)
-	Get(ctx context.Context, lat, lon float64, limit, offset int) ([]*domain.Incident, error)
+	Get(ctx context.Context, lat, lon float64, limit, offset int, filter domain.IncidentFilter) ([]*domain.Incident, error)
 	GetByID(ctx context.Context, id string) (*domain.Incident, error)
 	Update(ctx context.Context, id string, i *domain.Incident) (uuid.UUID, error)
 	Delete(ctx context.Context, id string) error
+	GetHistory(ctx context.Context, id string) ([]*domain.IncidentVersion, error)
+	Restore(ctx context.Context, id string, version int) (*domain.Incident, error)
+	Publish(ctx context.Context, id string) (*domain.Incident, error)
+	Resolve(ctx context.Context, id string) (*domain.Incident, error)
+	Reopen(ctx context.Context, id string) (*domain.Incident, error)
+	Archive(ctx context.Context, id string) (*domain.Incident, error)
 }
+
+// HealthChecker проверяет готовность сервиса принимать запросы
+type HealthChecker interface {
+	Ready(ctx context.Context) domain.HealthReport
+}
+
+// APIKeyService - проверка ключей для middleware и управление ключами для админских эндпоинтов
+type APIKeyService interface {
+	middleware.Authenticator
+	Issue(ctx context.Context, req domain.IssueAPIKeyRequest) (domain.IssuedAPIKey, error)
+	List(ctx context.Context) ([]*domain.APIKey, error)
+	Revoke(ctx context.Context, id string) error
+}
+
+// TenantService - определение организации запроса для middleware и управление организациями
+type TenantService interface {
+	middleware.TenantResolver
+	Create(ctx context.Context, req domain.TenantRequest) (*domain.Tenant, error)
+	List(ctx context.Context) ([]*domain.Tenant, error)
+	Update(ctx context.Context, id string, req domain.TenantRequest) (*domain.Tenant, error)
+}
+
+// AuditService - запись действий операторов для middleware и чтение журнала
+type AuditService interface {
+	middleware.AuditRecorder
+	List(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error)
+	Export(ctx context.Context, filter domain.AuditFilter, fn func(*domain.AuditEntry) error) error
+}
+
+// UserDataService - выгрузка и удаление данных пользователя по его запросу
+type UserDataService interface {
+	Export(ctx context.Context, userID string) (domain.UserDataExport, error)
+	Erase(ctx context.Context, userID string) (domain.UserErasure, error)
+}
+
 type Handler struct {
 	service   IncidentService
+	health    HealthChecker
+	keys      APIKeyService
+	tenants   TenantService
+	audit     AuditService
+	userData  UserDataService
+	jwt       middleware.JWTPolicy
+	rateLimit gin.HandlerFunc
 	statsTime int
+	lang      i18n.Lang
+	spec      *openapi3.T
+	proxies   []string
 }
 
-func NewHandler(s IncidentService, st int) *Handler {
+// rateLimit - уже настроенный MiddlewareRateLimit, ставится в группы после аутентификации,
+// lang - язык ответов для клиентов без Accept-Language, spec - спецификация API, по которой проверяются запросы,
+// proxies - адреса прокси, которым доверяем X-Forwarded-For при определении IP клиента
+func NewHandler(s IncidentService, health HealthChecker, keys APIKeyService, tenants TenantService, audit AuditService, userData UserDataService, jwt middleware.JWTPolicy, rateLimit gin.HandlerFunc, st int, lang i18n.Lang, spec *openapi3.T, proxies []string) *Handler {
 	return &Handler{
 		service:   s,
+		health:    health,
+		keys:      keys,
+		tenants:   tenants,
+		audit:     audit,
+		userData:  userData,
+		jwt:       jwt,
+		rateLimit: rateLimit,
 		statsTime: st,
+		lang:      lang,
+		spec:      spec,
+		proxies:   proxies,
 	}
 }
 
 // Init будет отвечать за регистрацию путей
-func (h *Handler) Init(api *gin.RouterGroup, apiKey string) {
+func (h *Handler) Init(api *gin.RouterGroup) {
 	v1 := api.Group("v1") //требуемый путь из ТЗ
+	//организация определяется после аутентификации: по ключу, токену или заголовку X-Tenant
+	tenant := middleware.MiddlewareTenant(h.tenants)
+	//каждый изменяющий запрос оператора записывается в журнал действий
+	audit := middleware.MiddlewareAudit(h.audit)
+	//параметры и тело проверяются по спецификации последними в группе: анонимный или превысивший лимит клиент
+	//получает 401 и 429, а не подробности о схеме запроса
+	validate := middleware.MiddlewareOpenAPI(h.spec)
 	{
-		//эндпоинт проверки координат для юзера
-		v1.POST("/location/check", h.checkLocation)
+		//эндпоинты конечных пользователей, токен портала проверяется в режиме, настроенном для маршрута
+		users := v1.Group("", middleware.MiddlewareJWT(h.jwt), tenant, h.rateLimit, validate)
+		{
+			//эндпоинт проверки координат для юзера
+			users.POST("/location/check", h.checkLocation)
+		}
 
 		incidents := v1.Group("/incidents")
 
 		//используем проверку на валидный ключ для группы эндпоинтов, которые использует оператор
-		incidents.Use(middleware.MiddlewareAuth(apiKey))
+		//каждому эндпоинту дополнительно нужно своё право (scope) ключа
+		incidents.Use(middleware.MiddlewareAuth(h.keys), tenant, audit, h.rateLimit, validate)
+		read := middleware.RequireScope(domain.ScopeIncidentsRead)
+		write := middleware.RequireScope(domain.ScopeIncidentsWrite)
+		stats := middleware.RequireScope(domain.ScopeStatsRead)
 		{
 			//эндпоинт для получения статистики за n минут
-			incidents.GET("/stats", h.GetStats)
+			incidents.GET("/stats", stats, h.GetStats)
+
+			//тепловая карта проверок координат по ячейкам geohash
+			incidents.GET("/heatmap", stats, h.GetHeatmap)
 
 			//CRUD для роли оператора по условиям ТЗ
 			//сначала указываются статические эндпоинты, а затем динамические, чтобы не возникла проблема затенения
 			//из-за специфики реализации роутинга групп эндпоинтов
-			incidents.POST("/", h.CreateIncident)
-			incidents.GET("/", h.GetIncidents)
-			incidents.GET("/:id", h.GetIncidentByID)
-			incidents.PUT("/:id", h.UpdateIncident)
-			incidents.DELETE("/:id", h.DeleteIncident)
+			incidents.POST("/", write, h.CreateIncident)
+			incidents.GET("/", read, h.GetIncidents)
+			incidents.GET("/:id", read, h.GetIncidentByID)
+			incidents.PUT("/:id", write, h.UpdateIncident)
+			incidents.DELETE("/:id", write, h.DeleteIncident)
+
+			//история изменений инцидента и восстановление одной из предыдущих версий
+			incidents.GET("/:id/history", read, h.GetIncidentHistory)
+			incidents.GET("/:id/stats", stats, h.GetIncidentStats)
+
+			//пользователи, которые прямо сейчас находятся в зоне инцидента
+			incidents.GET("/:id/users", stats, h.GetZoneUsers)
+			incidents.POST("/:id/history/:version/restore", write, h.RestoreIncident)
+
+			//переходы между статусами инцидента: draft → (scheduled →) active → resolved → archived
+			incidents.POST("/:id/publish", write, h.ChangeStatus(h.service.Publish))
+			incidents.POST("/:id/resolve", write, h.ChangeStatus(h.service.Resolve))
+			incidents.POST("/:id/reopen", write, h.ChangeStatus(h.service.Reopen))
+			incidents.POST("/:id/archive", write, h.ChangeStatus(h.service.Archive))
 		}
-		//health check по условию ТЗ
+
+		//управление API ключами без перезапуска сервиса
+		keys := v1.Group("/admin/keys", middleware.MiddlewareAuth(h.keys), tenant, audit, h.rateLimit, middleware.RequireScope(domain.ScopeKeysAdmin), validate)
+		{
+			keys.POST("/", h.IssueAPIKey)
+			keys.GET("/", h.ListAPIKeys)
+			keys.DELETE("/:id", h.RevokeAPIKey)
+		}
+
+		//управление организациями и их настройками, доступно только корневому ключу
+		tenants := v1.Group("/admin/tenants", middleware.MiddlewareAuth(h.keys), audit, h.rateLimit, middleware.RequireScope(domain.ScopeTenantsAdmin), validate)
+		{
+			tenants.POST("/", h.CreateTenant)
+			tenants.GET("/", h.ListTenants)
+			tenants.PUT("/:id", h.UpdateTenant)
+		}
+
+		//выгрузка и удаление данных пользователя, в журнал записывается и выгрузка: она раскрывает координаты
+		userAdmin := v1.Group("/admin/users", middleware.MiddlewareAuth(h.keys), tenant, middleware.MiddlewareAuditAll(h.audit), h.rateLimit, middleware.RequireScope(domain.ScopeUsersAdmin), validate)
+		{
+			userAdmin.GET("/:user_id/data", h.ExportUserData)
+			userAdmin.DELETE("/:user_id/data", h.EraseUserData)
+		}
+
+		//журнал действий операторов своей организации
+		auditLog := v1.Group("/admin/audit", middleware.MiddlewareAuth(h.keys), tenant, h.rateLimit, middleware.RequireScope(domain.ScopeAuditRead), validate)
+		{
+			auditLog.GET("/", h.GetAuditLog)
+			auditLog.GET("/export", h.ExportAuditLog)
+		}
+		//liveness - процесс жив и отвечает, readiness - доступны все зависимости
+		//system/health оставлен как liveness для обратной совместимости
 		v1.GET("/system/health", h.GetHealth)
+		v1.GET("/system/health/live", h.GetHealth)
+		v1.GET("/system/health/ready", h.GetReadiness)
+
+		//спецификация API и Swagger UI по ней, открыты без ключа
+		v1.GET("/openapi.json", h.GetOpenAPI)
+		v1.GET("/docs", h.GetDocs)
 	}
 }
 
-// Run отвечает за то, чтобы запустить http сервер на порту, и передать API ключ в метод инициализации роутинга
-func (h *Handler) Run(port string, apiKey string) error {
-	router := gin.Default()
+// Router собирает роутер со всеми middleware и эндпоинтами.
+// Сам http сервер (порт, таймауты, остановка) настраивается снаружи.
+// Если у какого-то маршрута API нет описания в спецификации, отдаётся ошибка и сервис не стартует
+func (h *Handler) Router() (http.Handler, error) {
+	//текстовые логи gin заменяем на JSON логи через MiddlewareLogger, отладочный вывод роутов оставляем только для debug
+	if !logger.IsDebug() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	//по IP клиента считаются лимиты и пишется журнал, поэтому X-Forwarded-For принимаем только от своих прокси,
+	//иначе клиент подставит любой адрес. Без настройки gin доверяет всем, nil - никому
+	if err := router.SetTrustedProxies(h.proxies); err != nil {
+		return nil, fmt.Errorf("некорректный список доверенных прокси: %w", err)
+	}
+	router.Use(gin.Recovery())
+	router.Use(middleware.MiddlewareRequestID())
+	router.Use(middleware.MiddlewareLanguage(h.lang))
+	//спан на каждый запрос, входящий traceparent подхватывается как родитель
+	router.Use(otelgin.Middleware(tracing.ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/metrics"
+	})))
+	router.Use(middleware.MiddlewareLogger())
+	router.Use(middleware.MiddlewareMetrics())
+
+	//метрики для prometheus отдаём вне /api, как принято для скрейпинга
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	//инициализируем роутинг
-	h.Init(router.Group("/api"), apiKey)
+	h.Init(router.Group("/api"))
 
-	return router.Run(":" + port)
+	if missing := middleware.UndocumentedRoutes(h.spec, router.Routes()); len(missing) > 0 {
+		return nil, fmt.Errorf("маршруты без описания в спецификации OpenAPI: %s", strings.Join(missing, ", "))
+	}
+	return router, nil
 }
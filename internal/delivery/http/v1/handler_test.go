@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"RedCollar/internal/delivery/http/middleware"
+	"RedCollar/internal/domain"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	cases := []struct {
+		name    string
+		proxies []string
+		want    string
+	}{
+		{"без настройки заголовку не верим", nil, "10.0.0.7"},
+		{"запрос от доверенного прокси", []string{"10.0.0.0/8"}, "203.0.113.5"},
+		{"запрос не от доверенного прокси", []string{"192.168.0.0/16"}, "10.0.0.7"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := newTestRouter(t, tc.proxies)
+			tr.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = "10.0.0.7:4321"
+			req.Header.Set("X-Forwarded-For", "203.0.113.5")
+			rec := httptest.NewRecorder()
+			tr.ServeHTTP(rec, req)
+			if got := rec.Body.String(); got != tc.want {
+				t.Fatalf("IP клиента %q, ожидали %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOrgKeyRejectsForeignTenantHeader(t *testing.T) {
+	tr := newTestRouter(t, nil)
+	body := `{"latitude": 55.75, "longitude": 37.61}`
+
+	rec := tr.do(http.MethodGet, "/api/v1/incidents/", "alpha", body, http.Header{"X-Tenant": {"beta"}})
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), domain.CodeTenantMismatch) {
+		t.Fatalf("ключ alpha с X-Tenant: beta: %d %s", rec.Code, rec.Body)
+	}
+	if len(tr.incidents.filters) != 0 {
+		t.Fatal("запрос с чужой организацией дошёл до сервиса")
+	}
+
+	rec = tr.do(http.MethodGet, "/api/v1/incidents/", "alpha", body, http.Header{"X-Tenant": {"alpha"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ключ alpha со своей организацией: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestFilterAcceptsCommaListAndRepeatedParams(t *testing.T) {
+	body := `{"latitude": 55.75, "longitude": 37.61}`
+	cases := []struct {
+		name       string
+		query      string
+		categories []domain.IncidentCategory
+		statuses   []domain.IncidentStatus
+	}{
+		{"категории через запятую", "category=fire,flood", []domain.IncidentCategory{domain.CategoryFire, domain.CategoryFlood}, nil},
+		{"повторяющаяся категория", "category=fire&category=flood", []domain.IncidentCategory{domain.CategoryFire, domain.CategoryFlood}, nil},
+		{"статусы через запятую", "status=active,resolved", nil, []domain.IncidentStatus{domain.StatusActive, domain.StatusResolved}},
+		{"повторяющийся статус", "status=active&status=resolved", nil, []domain.IncidentStatus{domain.StatusActive, domain.StatusResolved}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := newTestRouter(t, nil)
+			rec := tr.do(http.MethodGet, "/api/v1/incidents/?"+tc.query, "alpha", body, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("?%s: %d %s", tc.query, rec.Code, rec.Body)
+			}
+			if len(tr.incidents.filters) != 1 {
+				t.Fatalf("сервис вызван %d раз", len(tr.incidents.filters))
+			}
+			filter := tr.incidents.filters[0]
+			if !reflect.DeepEqual(filter.Categories, tc.categories) || !reflect.DeepEqual(filter.Statuses, tc.statuses) {
+				t.Fatalf("?%s: фильтр %+v", tc.query, filter)
+			}
+		})
+	}
+
+	tr := newTestRouter(t, nil)
+	rec := tr.do(http.MethodGet, "/api/v1/incidents/?category=fire,meteor", "alpha", body, nil)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), domain.CodeUnknownCategory) {
+		t.Fatalf("неизвестная категория: %d %s", rec.Code, rec.Body)
+	}
+}
+
+// userVerifier принимает только токен "portal-token" пользователя user-42 организации alpha
+type userVerifier struct{}
+
+func (userVerifier) Verify(token string) (domain.UserClaims, error) {
+	if token != "portal-token" {
+		return domain.UserClaims{}, errors.New("невалидный токен")
+	}
+	return domain.UserClaims{Subject: "user-42", Tenant: "alpha"}, nil
+}
+
+func TestCheckLocationTakesUserIDFromToken(t *testing.T) {
+	body := `{"user_id": "spoofed", "latitude": 55.75, "longitude": 37.61}`
+	cases := []struct {
+		name   string
+		mode   middleware.JWTMode
+		token  string
+		status int
+		userID string
+	}{
+		{"off: user_id из тела", middleware.JWTOff, "", http.StatusOK, "spoofed"},
+		{"optional без токена: user_id из тела", middleware.JWTOptional, "", http.StatusOK, "spoofed"},
+		{"optional с токеном: user_id из токена", middleware.JWTOptional, "portal-token", http.StatusOK, "user-42"},
+		{"required с токеном: user_id из токена", middleware.JWTRequired, "portal-token", http.StatusOK, "user-42"},
+		{"required без токена", middleware.JWTRequired, "", http.StatusUnauthorized, ""},
+		{"required с невалидным токеном", middleware.JWTRequired, "forged", http.StatusUnauthorized, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := newTestRouterWithJWT(t, nil, middleware.JWTPolicy{
+				Verifier: userVerifier{},
+				Default:  middleware.JWTOff,
+				Routes:   map[string]middleware.JWTMode{"/api/v1/location/check": tc.mode},
+			})
+			header := http.Header{"X-Tenant": {"alpha"}}
+			if tc.token != "" {
+				header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := tr.do(http.MethodPost, "/api/v1/location/check", "", body, header)
+			if rec.Code != tc.status {
+				t.Fatalf("статус %d, ожидали %d: %s", rec.Code, tc.status, rec.Body)
+			}
+			if tc.status != http.StatusOK {
+				if len(tr.incidents.checks) != 0 {
+					t.Fatal("запрос без валидного токена дошёл до сервиса")
+				}
+				return
+			}
+			if len(tr.incidents.checks) != 1 || tr.incidents.checks[0].UserID != tc.userID {
+				t.Fatalf("в сервис передан user_id %+v, ожидали %q", tr.incidents.checks, tc.userID)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"RedCollar/api"
+	"RedCollar/internal/delivery/http/middleware"
+	"RedCollar/internal/domain"
+	"RedCollar/internal/i18n"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// stubIncidents - сервис инцидентов для тестов роутера, непереопределённые методы паникуют
+type stubIncidents struct {
+	IncidentService
+	filters []domain.IncidentFilter       //фильтры, с которыми вызывали Get
+	checks  []domain.LocationCheckRequest //запросы, с которыми вызывали CheckLocation
+}
+
+func (s *stubIncidents) CheckLocation(ctx context.Context, req domain.LocationCheckRequest, limit, offset int, filter domain.IncidentFilter) (domain.LocationCheckResponse, error) {
+	s.checks = append(s.checks, req)
+	return domain.LocationCheckResponse{}, nil
+}
+
+func (s *stubIncidents) Get(ctx context.Context, lat, lon float64, limit, offset int, filter domain.IncidentFilter) ([]*domain.Incident, error) {
+	s.filters = append(s.filters, filter)
+	return []*domain.Incident{}, nil
+}
+
+// методы переходов передаются в роутер как значения, поэтому должны существовать
+func (s *stubIncidents) Publish(ctx context.Context, id string) (*domain.Incident, error) {
+	return nil, nil
+}
+
+func (s *stubIncidents) Resolve(ctx context.Context, id string) (*domain.Incident, error) {
+	return nil, nil
+}
+
+func (s *stubIncidents) Reopen(ctx context.Context, id string) (*domain.Incident, error) {
+	return nil, nil
+}
+
+func (s *stubIncidents) Archive(ctx context.Context, id string) (*domain.Incident, error) {
+	return nil, nil
+}
+
+// stubKeys пускает по токенам из tokens
+type stubKeys struct {
+	APIKeyService
+	tokens map[string]*domain.APIKey
+}
+
+func (s stubKeys) Authenticate(ctx context.Context, token string) (*domain.APIKey, error) {
+	if key, ok := s.tokens[token]; ok {
+		return key, nil
+	}
+	return nil, domain.ErrInvalidAPIKey
+}
+
+// stubTenants хранит организации в памяти
+type stubTenants struct {
+	TenantService
+	tenants []*domain.Tenant
+}
+
+func (s stubTenants) Get(ctx context.Context, id uuid.UUID) (*domain.Tenant, error) {
+	for _, t := range s.tenants {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return nil, domain.ErrTenantNotFound
+}
+
+func (s stubTenants) GetBySlug(ctx context.Context, slug string) (*domain.Tenant, error) {
+	for _, t := range s.tenants {
+		if t.Slug == slug {
+			return t, nil
+		}
+	}
+	return nil, domain.ErrTenantNotFound
+}
+
+// stubAudit ничего не записывает
+type stubAudit struct {
+	AuditService
+}
+
+func (stubAudit) Record(ctx context.Context, entry *domain.AuditEntry) {}
+
+// testRouter - роутер с настоящими middleware и спецификацией поверх заглушек сервисов
+type testRouter struct {
+	*gin.Engine
+	incidents *stubIncidents
+	tenants   []*domain.Tenant
+	tokens    map[string]*domain.APIKey
+}
+
+func newTestRouter(t *testing.T, proxies []string) *testRouter {
+	t.Helper()
+	return newTestRouterWithJWT(t, proxies, middleware.JWTPolicy{Default: middleware.JWTOff})
+}
+
+// newTestRouterWithJWT - то же самое с заданной проверкой токенов пользователей
+func newTestRouterWithJWT(t *testing.T, proxies []string, jwt middleware.JWTPolicy) *testRouter {
+	t.Helper()
+	spec, err := api.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &testRouter{
+		incidents: &stubIncidents{},
+		tenants: []*domain.Tenant{
+			{ID: uuid.New(), Slug: "alpha"},
+			{ID: uuid.New(), Slug: "beta"},
+		},
+	}
+	//у каждой организации свой ключ с правом чтения инцидентов
+	tr.tokens = map[string]*domain.APIKey{}
+	for _, tenant := range tr.tenants {
+		tr.tokens["token-"+tenant.Slug] = &domain.APIKey{ID: uuid.New(), TenantID: tenant.ID, Scopes: []domain.Scope{domain.ScopeIncidentsRead}}
+	}
+	passthrough := func(c *gin.Context) { c.Next() }
+	h := NewHandler(tr.incidents, nil, stubKeys{tokens: tr.tokens}, stubTenants{tenants: tr.tenants}, stubAudit{}, nil, jwt, passthrough, 60, i18n.RU, spec, proxies)
+	router, err := h.Router()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Engine = router.(*gin.Engine)
+	return tr
+}
+
+// do выполняет запрос с API ключом организации slug
+func (tr *testRouter) do(method, target, slug, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if slug != "" {
+		req.Header.Set("X-API-Key", "token-"+slug)
+	}
+	rec := httptest.NewRecorder()
+	tr.ServeHTTP(rec, req)
+	return rec
+}
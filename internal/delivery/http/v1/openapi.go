@@ -0,0 +1,30 @@
+package v1
+
+import "github.com/gin-gonic/gin"
+
+// docsPage - Swagger UI по спецификации сервиса, статика интерфейса берётся с CDN
+const docsPage = `<!DOCTYPE html>
+<html lang="ru">
+<head>
+  <meta charset="utf-8">
+  <title>RedCollar API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>`
+
+// GET /api/v1/openapi.json
+func (h *Handler) GetOpenAPI(c *gin.Context) {
+	c.JSON(200, h.spec)
+}
+
+// GET /api/v1/docs
+func (h *Handler) GetDocs(c *gin.Context) {
+	c.Data(200, "text/html; charset=utf-8", []byte(docsPage))
+}
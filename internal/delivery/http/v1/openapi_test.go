@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"RedCollar/api"
+	"RedCollar/internal/delivery/http/middleware"
+	"RedCollar/internal/domain"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestSpecMatchesRoutes - каждый маршрут API описан в спецификации, и каждая операция спецификации реализована
+func TestSpecMatchesRoutes(t *testing.T) {
+	spec, err := api.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := newTestRouter(t, nil).Routes()
+
+	if missing := middleware.UndocumentedRoutes(spec, routes); len(missing) > 0 {
+		t.Errorf("маршруты без описания в спецификации: %v", missing)
+	}
+	if missing := middleware.UnimplementedOperations(spec, routes); len(missing) > 0 {
+		t.Errorf("операции спецификации без маршрута: %v", missing)
+	}
+}
+
+// TestSpecValidationRunsAfterAuth - запрос без ключа отклоняется аутентификацией, а не проверкой по спецификации
+func TestSpecValidationRunsAfterAuth(t *testing.T) {
+	tr := newTestRouter(t, nil)
+	body := `{"latitude": 55.75, "longitude": 37.61}`
+
+	rec := tr.do(http.MethodGet, "/api/v1/incidents/?limit=many", "", body, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("невалидный запрос без ключа: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = tr.do(http.MethodGet, "/api/v1/incidents/?limit=many", "alpha", body, nil)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), domain.CodeInvalidParameter) {
+		t.Fatalf("невалидный запрос с ключом: %d %s", rec.Code, rec.Body)
+	}
+}
+
+// TestSpecKeepsDomainErrorCodes - значения, которые проверяет сервис, отклоняются с его кодами, а не schema_violation
+func TestSpecKeepsDomainErrorCodes(t *testing.T) {
+	cases := []struct {
+		name   string
+		target string
+		body   string
+		code   string
+	}{
+		{"широта вне диапазона", "/api/v1/incidents/", `{"latitude": 200, "longitude": 37.61}`, domain.CodeLatitudeRange},
+		{"долгота вне диапазона", "/api/v1/incidents/", `{"latitude": 55.75, "longitude": -500}`, domain.CodeLongitudeRange},
+		{"уровень опасности вне диапазона", "/api/v1/incidents/?min_severity=9", `{"latitude": 55.75, "longitude": 37.61}`, domain.CodeInvalidMinSeverity},
+		{"категория не из списка", "/api/v1/incidents/?category=Fire", `{"latitude": 55.75, "longitude": 37.61}`, domain.CodeUnknownCategory},
+		{"статус не из списка", "/api/v1/incidents/?status=closed", `{"latitude": 55.75, "longitude": 37.61}`, domain.CodeUnknownStatus},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := newTestRouter(t, nil)
+			rec := tr.do(http.MethodGet, tc.target, "alpha", tc.body, nil)
+			if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"`+tc.code+`"`) {
+				t.Fatalf("ожидали 422 %s: %d %s", tc.code, rec.Code, rec.Body)
+			}
+		})
+	}
+}
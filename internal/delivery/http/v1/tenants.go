@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"RedCollar/internal/delivery/http/problem"
+	"RedCollar/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/v1/admin/tenants
+func (h *Handler) CreateTenant(c *gin.Context) {
+	var req domain.TenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, errInvalidBody(err))
+		return
+	}
+
+	tenant, err := h.tenants.Create(c.Request.Context(), req)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(201, tenant)
+}
+
+// GET /api/v1/admin/tenants
+func (h *Handler) ListTenants(c *gin.Context) {
+	tenants, err := h.tenants.List(c.Request.Context())
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(200, tenants)
+}
+
+// PUT /api/v1/admin/tenants/:id
+func (h *Handler) UpdateTenant(c *gin.Context) {
+	var req domain.TenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, errInvalidBody(err))
+		return
+	}
+
+	tenant, err := h.tenants.Update(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	//на других репликах новые настройки применятся после истечения кэша организаций
+	c.JSON(200, tenant)
+}
@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"RedCollar/internal/delivery/http/middleware"
+	"RedCollar/internal/delivery/http/problem"
+	"RedCollar/internal/domain"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userDataCSVHeader - колонки выгрузки данных пользователя: проверки и доставки вебхуков в одной таблице,
+// record показывает, к чему относится строка, а лишние для неё колонки остаются пустыми
+var userDataCSVHeader = []string{"record", "id", "time", "lat", "lon", "incident_ids", "event", "url", "delivered", "error"}
+
+func userDataCSVRows(export domain.UserDataExport) [][]string {
+	rows := make([][]string, 0, len(export.Checks)+len(export.Deliveries))
+	for _, check := range export.Checks {
+		ids := make([]string, len(check.IncidentIDs))
+		for idx, id := range check.IncidentIDs {
+			ids[idx] = id.String()
+		}
+		rows = append(rows, []string{
+			"location_check", strconv.FormatInt(check.ID, 10), check.CheckedAt.Format(time.RFC3339),
+			strconv.FormatFloat(check.Latitude, 'f', -1, 64), strconv.FormatFloat(check.Longitude, 'f', -1, 64),
+			strings.Join(ids, " "), "", "", "", "",
+		})
+	}
+	for _, d := range export.Deliveries {
+		rows = append(rows, []string{
+			"webhook_delivery", strconv.FormatInt(d.ID, 10), d.CreatedAt.Format(time.RFC3339), "", "",
+			d.IncidentID.String(), string(d.Event), d.URL, strconv.FormatBool(d.Delivered), d.Error,
+		})
+	}
+	return rows
+}
+
+// GET /api/v1/admin/users/:user_id/data?format=json|csv
+func (h *Handler) ExportUserData(c *gin.Context) {
+	userID := c.Param("user_id")
+	c.Set(middleware.AuditTargetKey, userID)
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		problem.Write(c, domain.BadRequest(domain.CodeInvalidFormat, "format должен быть json или csv").OnField("format"))
+		return
+	}
+
+	export, err := h.userData.Export(c.Request.Context(), userID)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	if format == "json" {
+		c.JSON(200, export)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="user-data.csv"`)
+	c.Status(200)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(userDataCSVHeader)
+	_ = w.WriteAll(userDataCSVRows(export))
+}
+
+// DELETE /api/v1/admin/users/:user_id/data
+func (h *Handler) EraseUserData(c *gin.Context) {
+	userID := c.Param("user_id")
+	c.Set(middleware.AuditTargetKey, userID)
+
+	erasure, err := h.userData.Erase(c.Request.Context(), userID)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(200, erasure)
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidAPIKey - ключ не найден, не совпал, истёк или отозван. Причину наружу не раскрываем
+var ErrInvalidAPIKey = errors.New("невалидный API ключ")
+
+// Scope - право, выданное API ключу
+type Scope string
+
+const (
+	ScopeIncidentsRead  Scope = "incidents:read"  //просмотр инцидентов и их истории
+	ScopeIncidentsWrite Scope = "incidents:write" //создание, изменение и смена статуса инцидентов
+	ScopeStatsRead      Scope = "stats:read"      //статистика, тепловая карта и пользователи в зоне
+	ScopeWebhooksAdmin  Scope = "webhooks:admin"  //управление доставкой вебхуков
+	ScopeKeysAdmin      Scope = "keys:admin"      //выпуск и отзыв API ключей своей организации
+	ScopeTenantsAdmin   Scope = "tenants:admin"   //управление организациями, есть только у корневого ключа
+	ScopeAuditRead      Scope = "audit:read"      //просмотр и выгрузка журнала действий операторов
+	ScopeUsersAdmin     Scope = "users:admin"     //выгрузка и удаление данных пользователя по его запросу
+)
+
+// AllScopes - все существующие права, их получает корневой ключ из API_KEY
+var AllScopes = []Scope{ScopeIncidentsRead, ScopeIncidentsWrite, ScopeStatsRead, ScopeWebhooksAdmin, ScopeKeysAdmin, ScopeTenantsAdmin, ScopeAuditRead, ScopeUsersAdmin}
+
+func (s Scope) IsValid() bool {
+	return slices.Contains(AllScopes, s)
+}
+
+// APIKey - ключ оператора или интеграции. Сам ключ не хранится, только его хэш
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	TenantID   uuid.UUID  `json:"tenant_id"` //организация ключа, у корневого ключа - uuid.Nil (выбирает организацию заголовком X-Tenant)
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` //открытая часть ключа, по ней ключ находится в базе и узнаётся в списке
+	Scopes     []Scope    `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope проверяет, выдано ли ключу право
+func (k *APIKey) HasScope(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// IsUsableAt - ключ не отозван и не истёк
+func (k *APIKey) IsUsableAt(t time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || t.Before(*k.ExpiresAt)
+}
+
+// IssuedAPIKey - только что выпущенный ключ, Token показывается один раз и больше нигде не хранится
+type IssuedAPIKey struct {
+	APIKey
+	Token string `json:"token"`
+}
+
+// IssueAPIKeyRequest - тело запроса на выпуск ключа
+type IssueAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []Scope    `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"` //пустое значение - бессрочный ключ
+}
+
+// apiKeyKey - ключ в контексте для API ключа, которым выполнен запрос
+type apiKeyKey struct{}
+
+// WithAPIKey сохраняет в контексте API ключ, которым выполнен запрос
+func WithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// APIKeyFromContext достаёт API ключ запроса, nil - запрос без ключа (публичный эндпоинт или фоновая задача)
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyKey{}).(*APIKey)
+	return key
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxAuditLimit - сколько записей журнала можно получить за один запрос
+const MaxAuditLimit = 500
+
+// AuditEntry - запись журнала действий оператора. Записи только добавляются, изменить или удалить их нельзя
+type AuditEntry struct {
+	ID         int64      `json:"id"`
+	TenantID   uuid.UUID  `json:"tenant_id"`
+	ActorKeyID *uuid.UUID `json:"actor_key_id"` //ID API ключа, nil - корневой ключ из API_KEY
+	Actor      string     `json:"actor"`        //имя ключа с необязательным X-Operator, как в истории инцидентов
+	Method     string     `json:"method"`
+	Route      string     `json:"route"`                 //шаблон маршрута, например /api/v1/incidents/:id
+	IncidentID *uuid.UUID `json:"incident_id,omitempty"` //инцидент, над которым выполнялось действие
+	Target     string     `json:"target,omitempty"`      //user_id при выгрузке и удалении данных, в режиме приватности - его псевдоним
+	BodySHA256 string     `json:"body_sha256"`           //хэш тела запроса, само тело не храним
+	IP         string     `json:"ip"`
+	Status     int        `json:"status"` //HTTP статус ответа
+	RequestID  string     `json:"request_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Succeeded - действие выполнено (ответ 2xx)
+func (e *AuditEntry) Succeeded() bool {
+	return e.Status >= 200 && e.Status < 300
+}
+
+// AuditResult - фильтр журнала по результату действия
+type AuditResult string
+
+const (
+	AuditResultSuccess AuditResult = "success" //ответ 2xx
+	AuditResultFailure AuditResult = "failure" //любой другой ответ, в том числе отказ в доступе
+)
+
+// AuditFilter - фильтры и пагинация журнала, пустые поля не ограничивают выборку
+type AuditFilter struct {
+	ActorKeyID *uuid.UUID
+	IncidentID *uuid.UUID
+	Targets    []string //значения target, под которыми может храниться пользователь: user_id и его псевдоним
+	Route      string
+	Method     string
+	Result     AuditResult
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+func (f *AuditFilter) Validate() error {
+	if f.Result != "" && f.Result != AuditResultSuccess && f.Result != AuditResultFailure {
+		return Invalid(CodeInvalidAuditResult, "result должен быть success или failure").OnField("result")
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return Invalid(CodeInvalidPeriod, "конец периода должен быть позже начала").OnField("to")
+	}
+	if f.Limit < 0 || f.Offset < 0 {
+		return Invalid(CodeNegativePagination, "limit и offset не могут быть отрицательными")
+	}
+	return nil
+}
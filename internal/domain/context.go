@@ -0,0 +1,56 @@
+package domain
+
+import "context"
+
+// actorKey - приватный тип ключа, чтобы значение в контексте не пересеклось с ключами других пакетов
+type actorKey struct{}
+
+// ActorSystem используется для изменений, которые делает сам сервис (например планировщик), а не оператор
+const ActorSystem = "system"
+
+// WithActor сохраняет в контексте того, кто выполняет изменение (оператор или фоновая задача)
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext достаёт из контекста того, кто выполняет изменение, если никого нет - считаем что это сервис
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorSystem
+}
+
+// requestIDKey - ключ ID запроса в контексте
+type requestIDKey struct{}
+
+// WithRequestID сохраняет в контексте ID запроса, по которому связываются логи и вебхуки одного запроса
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext достаёт ID запроса из контекста, пустая строка - запроса нет (например при старте сервиса)
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// subjectKey - ключ в контексте для ID пользователя из проверенного JWT
+type subjectKey struct{}
+
+// WithSubject сохраняет в контексте ID пользователя, подтверждённый подписью токена
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFromContext достаёт ID пользователя из токена, пустая строка - запрос без токена
+func SubjectFromContext(ctx context.Context) string {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
+
+// UserClaims - данные пользователя из проверенного JWT
+type UserClaims struct {
+	Subject string //ID пользователя
+	Tenant  string //slug организации из claim tenant, пустая строка - claim не передан
+}
@@ -0,0 +1,190 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind - класс ошибки, по нему HTTP слой выбирает статус ответа
+type ErrorKind int
+
+const (
+	KindInternal        ErrorKind = iota //сбой сервиса или зависимостей, детали клиенту не показываются
+	KindBadRequest                       //запрос не разобрать: не JSON, не UUID, не число
+	KindValidation                       //запрос разобран, но значения полей недопустимы
+	KindNotFound                         //объекта нет (или он принадлежит другой организации)
+	KindConflict                         //действие противоречит текущему состоянию объекта
+	KindUnauthorized                     //нет учётных данных или они неверны
+	KindForbidden                        //учётные данные верны, но прав не хватает
+	KindTooManyRequests                  //превышен лимит частоты запросов
+)
+
+// Машиночитаемые коды ошибок, клиенты различают ошибки по ним, а не по тексту.
+// У каждого кода один смысл, поэтому по коду можно подобрать текст на нужном языке
+const (
+	CodeInternal       = "internal_error"
+	CodeInvalidBody    = "invalid_body"
+	CodeInvalidID      = "invalid_id"
+	CodeQueryNotNumber = "query_not_number"
+	CodeQueryNotTime   = "query_not_time"
+	CodeInvalidFormat  = "invalid_format"
+
+	//запрос не соответствует спецификации OpenAPI
+	CodeInvalidParameter = "invalid_parameter"
+	CodeSchemaViolation  = "schema_violation"
+	CodeUnsupportedMedia = "unsupported_media_type"
+
+	//инциденты
+	CodeLatitudeRange      = "latitude_out_of_range"
+	CodeLongitudeRange     = "longitude_out_of_range"
+	CodeCoordinatesMissing = "coordinates_missing"
+	CodeTitleEmpty         = "title_empty"
+	CodeTitleTooLong       = "title_too_long"
+	CodeDescriptionEmpty   = "description_empty"
+	CodeDescriptionTooLong = "description_too_long"
+	CodeUnknownCategory    = "unknown_category"
+	CodeInvalidSeverity    = "invalid_severity"
+	CodeInvalidMinSeverity = "invalid_min_severity"
+	CodeUnknownStatus      = "unknown_status"
+	CodeArchivedHidden     = "archived_hidden"
+	CodeInvalidSchedule    = "invalid_schedule"
+	CodeValidUntilPassed   = "valid_until_passed"
+	CodeInitialStatus      = "invalid_initial_status"
+	CodeInvalidVersion     = "invalid_version"
+	CodeIncidentNotFound   = "incident_not_found"
+	CodeVersionNotFound    = "version_not_found"
+	CodeStatusTransition   = "status_transition_forbidden"
+	CodeReopenNotResolved  = "reopen_not_resolved"
+	CodeScheduleExpired    = "schedule_expired"
+	CodeIncidentArchived   = "incident_archived"
+	CodeStatusChanged      = "status_changed"
+	CodeIncidentNotActive  = "incident_not_active"
+
+	//статистика и пользователи в зоне
+	CodeInvalidPeriod    = "invalid_period"
+	CodeUnknownStatsMode = "unknown_stats_mode"
+	CodeUnknownBucket    = "unknown_bucket"
+	CodeTooManyBuckets   = "too_many_buckets"
+	CodeInvalidPrecision = "invalid_precision"
+	CodeInvalidMaxAge    = "invalid_max_age"
+
+	//доступ
+	CodeAPIKeyRequired = "api_key_required"
+	CodeInvalidAPIKey  = "invalid_api_key"
+	CodeMissingScope   = "missing_scope"
+	CodeTokenRequired  = "token_required"
+	CodeInvalidToken   = "invalid_token"
+	CodeTenantNotFound = "tenant_not_found"
+	CodeTenantMismatch = "tenant_mismatch"
+	CodeRateLimited    = "rate_limited"
+
+	//администрирование
+	CodeTenantExists          = "tenant_exists"
+	CodeInvalidSlug           = "invalid_slug"
+	CodeTenantNameEmpty       = "tenant_name_empty"
+	CodeInvalidWarningZone    = "invalid_warning_zone"
+	CodeInvalidStatsWindow    = "invalid_stats_window"
+	CodeInvalidWebhookRetries = "invalid_webhook_retries"
+	CodeInvalidWebhookTimeout = "invalid_webhook_timeout"
+	CodeAPIKeyNotFound        = "api_key_not_found"
+	CodeInvalidKeyName        = "invalid_key_name"
+	CodeScopesEmpty           = "scopes_empty"
+	CodeUnknownScope          = "unknown_scope"
+	CodeScopeNotGrantable     = "scope_not_grantable"
+	CodeScopeNotHeld          = "scope_not_held"
+	CodeExpiresInPast         = "expires_in_past"
+	CodeInvalidAuditResult    = "invalid_audit_result"
+	CodeNegativePagination    = "negative_pagination"
+	CodeInvalidUserID         = "invalid_user_id"
+)
+
+// FieldError - ошибка в конкретном поле запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Args    []any  `json:"-"` //аргументы текста, чтобы собрать его на языке клиента
+}
+
+// Error - ошибка с классом и кодом, которую сервис отдаёт наружу вместо текста.
+// Message показывается клиенту (на другом языке текст собирается по Code и Args), Err - внутренняя причина только для логов
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Args    []any
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is позволяет проверять класс ошибки через errors.Is(err, domain.ErrNotFound)
+func (e *Error) Is(target error) bool {
+	var t *Error
+	if !errors.As(target, &t) || t.Code != "" {
+		return false
+	}
+	return t.Kind == e.Kind
+}
+
+// Ошибки-образцы для errors.Is: совпадают с любой ошибкой своего класса
+var (
+	ErrNotFound     = &Error{Kind: KindNotFound}
+	ErrValidation   = &Error{Kind: KindValidation}
+	ErrConflict     = &Error{Kind: KindConflict}
+	ErrUnauthorized = &Error{Kind: KindUnauthorized}
+)
+
+func newError(kind ErrorKind, code, format string, args ...any) *Error {
+	return &Error{Kind: kind, Code: code, Message: fmt.Sprintf(format, args...), Args: args}
+}
+
+func BadRequest(code, format string, args ...any) *Error {
+	return newError(KindBadRequest, code, format, args...)
+}
+
+func Invalid(code, format string, args ...any) *Error {
+	return newError(KindValidation, code, format, args...)
+}
+
+func NotFound(code, format string, args ...any) *Error {
+	return newError(KindNotFound, code, format, args...)
+}
+
+func Conflict(code, format string, args ...any) *Error {
+	return newError(KindConflict, code, format, args...)
+}
+
+func Unauthorized(code, format string, args ...any) *Error {
+	return newError(KindUnauthorized, code, format, args...)
+}
+
+func Forbidden(code, format string, args ...any) *Error {
+	return newError(KindForbidden, code, format, args...)
+}
+
+func TooManyRequests(code, format string, args ...any) *Error {
+	return newError(KindTooManyRequests, code, format, args...)
+}
+
+// OnField привязывает ошибку к полю запроса, чтобы клиент мог подсветить его
+func (e *Error) OnField(field string) *Error {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: e.Code, Message: e.Message, Args: e.Args})
+	return e
+}
+
+// Wrap сохраняет внутреннюю причину, клиенту она не показывается
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
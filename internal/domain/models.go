@@ -1,20 +1,148 @@
 package domain
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// IncidentCategory - тип инцидента, по которому портал отличает, например, перекрытие дороги от лесного пожара
+type IncidentCategory string
+
+const (
+	CategoryFire       IncidentCategory = "fire"       //Пожар
+	CategoryFlood      IncidentCategory = "flood"      //Наводнение
+	CategoryChemical   IncidentCategory = "chemical"   //Химическая опасность
+	CategoryRoad       IncidentCategory = "road"       //Дорожный инцидент (перекрытие, ДТП)
+	CategoryEarthquake IncidentCategory = "earthquake" //Землетрясение
+	CategoryStorm      IncidentCategory = "storm"      //Шторм, ураган
+	CategoryOther      IncidentCategory = "other"      //Всё остальное (дефолт)
+)
+
+// IsValid проверяет, что категория входит в список известных
+func (c IncidentCategory) IsValid() bool {
+	switch c {
+	case CategoryFire, CategoryFlood, CategoryChemical, CategoryRoad, CategoryEarthquake, CategoryStorm, CategoryOther:
+		return true
+	}
+	return false
+}
+
+// Severity - уровень опасности инцидента, чем больше число - тем опаснее
+type Severity int
+
+const (
+	SeverityLow      Severity = 1 //Незначительный (дефолт)
+	SeverityMedium   Severity = 2 //Средний
+	SeverityHigh     Severity = 3 //Высокий
+	SeverityCritical Severity = 4 //Критический
+)
+
+// IsValid проверяет, что уровень опасности находится в допустимом диапазоне
+func (s Severity) IsValid() bool {
+	return s >= SeverityLow && s <= SeverityCritical
+}
+
+// IncidentStatus - этап жизненного цикла инцидента: draft → (scheduled →) active → resolved → archived
+type IncidentStatus string
+
+const (
+	StatusDraft     IncidentStatus = "draft"     //Черновик, ещё не опубликован и не участвует в проверках
+	StatusScheduled IncidentStatus = "scheduled" //Опубликован, но valid_from ещё не наступил
+	StatusActive    IncidentStatus = "active"    //Действует, участвует в проверках координат
+	StatusResolved  IncidentStatus = "resolved"  //Завершён, виден в истории и статистике
+	StatusArchived  IncidentStatus = "archived"  //Убран в архив и скрыт из списков и статистики
+)
+
+// statusTransitions описывает разрешённые переходы между статусами, archived - конечный статус
+var statusTransitions = map[IncidentStatus][]IncidentStatus{
+	StatusDraft:     {StatusScheduled, StatusActive, StatusArchived},
+	StatusScheduled: {StatusActive, StatusResolved, StatusDraft},
+	StatusActive:    {StatusResolved},
+	StatusResolved:  {StatusScheduled, StatusActive, StatusArchived},
+	StatusArchived:  {},
+}
+
+// IsValid проверяет, что статус входит в список известных
+func (s IncidentStatus) IsValid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo проверяет, можно ли перевести инцидент из статуса s в статус to
+func (s IncidentStatus) CanTransitionTo(to IncidentStatus) bool {
+	return slices.Contains(statusTransitions[s], to)
+}
+
 type Incident struct { // Тело инцидента
-	ID           uuid.UUID `json:"id"`            //UUID
-	Title        string    `json:"title"`         //Заголовок устанавливаемый оператором (например "Пожар")
-	Description  string    `json:"description"`   //Описание инцидента (например "Огонь разрастается в Южную сторону")
-	Latitude     float64   `json:"latitude"`      //Широта
-	Longitude    float64   `json:"longitude"`     //Долгота
-	RadiusMeters float64   `json:"radius_meters"` //Радиус опасной зоны
-	IsActive     bool      `json:"is_active"`     //Активен ли инцидент
-	CreatedAt    time.Time `json:"created_at"`    //Время инициализации инцидента (по условию нужно вернуть user_count за N минут)
+	ID           uuid.UUID        `json:"id"`            //UUID
+	Title        string           `json:"title"`         //Заголовок устанавливаемый оператором (например "Пожар")
+	Description  string           `json:"description"`   //Описание инцидента (например "Огонь разрастается в Южную сторону")
+	Category     IncidentCategory `json:"category"`      //Категория инцидента (fire, flood, chemical, road и тд)
+	Severity     Severity         `json:"severity"`      //Уровень опасности от 1 до 4
+	Latitude     float64          `json:"latitude"`      //Широта
+	Longitude    float64          `json:"longitude"`     //Долгота
+	RadiusMeters float64          `json:"radius_meters"` //Радиус опасной зоны
+	Status       IncidentStatus   `json:"status"`        //Статус инцидента (draft, scheduled, active, resolved, archived)
+	ValidFrom    *time.Time       `json:"valid_from"`    //Время автоматической активации запланированного инцидента (nil - сразу)
+	ValidUntil   *time.Time       `json:"valid_until"`   //Время автоматического завершения инцидента (nil - пока оператор не удалит)
+	CreatedAt    time.Time        `json:"created_at"`    //Время инициализации инцидента (по условию нужно вернуть user_count за N минут)
+	TenantID     uuid.UUID        `json:"-"`             //Организация, которой принадлежит инцидент
+}
+
+// InWindow проверяет, попадает ли момент t в окно действия инцидента [ValidFrom, ValidUntil)
+func (i *Incident) InWindow(t time.Time) bool {
+	if i.ValidFrom != nil && t.Before(*i.ValidFrom) {
+		return false
+	}
+	if i.ValidUntil != nil && !t.Before(*i.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// IsActiveAt проверяет, действует ли инцидент в момент t: он в статусе active и t попадает в окно действия
+func (i *Incident) IsActiveAt(t time.Time) bool {
+	return i.Status == StatusActive && i.InWindow(t)
+}
+
+// PublishedStatus отдаёт статус, который получает опубликованный в момент t инцидент:
+// scheduled, если valid_from ещё не наступил, иначе active
+func (i *Incident) PublishedStatus(t time.Time) IncidentStatus {
+	if i.ValidFrom != nil && t.Before(*i.ValidFrom) {
+		return StatusScheduled
+	}
+	return StatusActive
+}
+
+// IncidentAction - тип изменения инцидента, которое записывается в историю
+type IncidentAction string
+
+const (
+	ActionCreate  IncidentAction = "create"  //Инцидент создан
+	ActionUpdate  IncidentAction = "update"  //Инцидент изменён оператором
+	ActionStatus  IncidentAction = "status"  //Изменён статус инцидента (оператором или планировщиком)
+	ActionRestore IncidentAction = "restore" //Инцидент восстановлен из предыдущей версии
+)
+
+// IncidentVersion - одна запись в истории изменений инцидента
+type IncidentVersion struct {
+	IncidentID   uuid.UUID      `json:"incident_id"`             //UUID инцидента
+	Version      int            `json:"version"`                 //Порядковый номер версии, начиная с 1
+	Action       IncidentAction `json:"action"`                  //Что произошло
+	Actor        string         `json:"actor"`                   //Кто сделал изменение
+	ChangedAt    time.Time      `json:"changed_at"`              //Когда
+	OldValue     *Incident      `json:"old_value"`               //Состояние до изменения (nil для create)
+	NewValue     *Incident      `json:"new_value"`               //Состояние после изменения
+	RestoredFrom *int           `json:"restored_from,omitempty"` //Номер версии, из которой восстановлен инцидент
+}
+
+// IncidentFilter - необязательные фильтры для списка инцидентов и проверки координат, пустые поля не фильтруют
+type IncidentFilter struct {
+	Categories  []IncidentCategory `json:"categories,omitempty"`   //Оставить только инциденты указанных категорий
+	MinSeverity Severity           `json:"min_severity,omitempty"` //Оставить только инциденты с уровнем опасности не ниже указанного
+	Statuses    []IncidentStatus   `json:"statuses,omitempty"`     //Оставить только инциденты в указанных статусах (по умолчанию active)
 }
 
 type LocationCheckRequest struct { // Структура запроса геоданных пользователя которую мы будем валидировать (т.е. то, что мы просим у пользователя)
@@ -28,12 +156,177 @@ type LocationCheckResponse struct { //То что пользователь по
 }
 
 type StatisticResponse struct { //Структура ответа для эндпоинта /api/v1/incidents/stats (по условию задачи)
-	IncidentID string `json:"incident_id"` //UUID
-	UserCount  int    `json:"user_count"`  //Количество пользователей попавших в радиус инцидента пока инцидент был IsActive true
+	IncidentID  string       `json:"incident_id"`           //UUID
+	UserCount   int          `json:"user_count"`            //Количество пользователей попавших в радиус инцидента пока инцидент был в статусе active
+	Series      []StatsPoint `json:"series,omitempty"`      //Количество уникальных пользователей по интервалам, если запрошена разбивка
+	Approximate bool         `json:"approximate,omitempty"` //true, если значения посчитаны по HyperLogLog в redis (погрешность ~1%) или по свёрнутым часам (оценка сверху)
+}
+
+// StatsMode - способ подсчёта статистики
+type StatsMode string
+
+const (
+	StatsApprox StatsMode = "approx" //HyperLogLog счётчики в redis, быстро, но с погрешностью (по умолчанию)
+	StatsExact  StatsMode = "exact"  //COUNT(DISTINCT) по location_checks в postgres, точно, но медленно
+)
+
+// StatsBucket - размер интервала, на которые разбивается статистика
+type StatsBucket string
+
+const (
+	BucketMinute StatsBucket = "minute"
+	BucketHour   StatsBucket = "hour"
+	BucketDay    StatsBucket = "day"
+)
+
+// Duration отдаёт длительность интервала, для неизвестного значения - 0
+func (b StatsBucket) Duration() time.Duration {
+	switch b {
+	case BucketMinute:
+		return time.Minute
+	case BucketHour:
+		return time.Hour
+	case BucketDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// StatsQuery - параметры запроса статистики, пустой Bucket означает статистику без разбивки
+type StatsQuery struct {
+	From       time.Time   //Начало периода (включительно)
+	To         time.Time   //Конец периода (не включительно)
+	Bucket     StatsBucket //Размер интервала разбивки
+	Mode       StatsMode   //Способ подсчёта, пустой - approx, если период помещается в срок хранения счётчиков
+	IncidentID *uuid.UUID  //Если указан - статистика только по одному инциденту
+}
+
+// UniqueUsersRange - период [From, To), за который считаются уникальные пользователи одного инцидента
+type UniqueUsersRange struct {
+	IncidentID string
+	From       time.Time
+	To         time.Time
+}
+
+// StatsPoint - количество уникальных пользователей за один интервал
+type StatsPoint struct {
+	BucketStart time.Time `json:"bucket_start"` //Начало интервала (UTC)
+	UserCount   int       `json:"user_count"`   //Количество уникальных пользователей за интервал
 }
 
+// WebhookEvent - тип события, о котором сообщает вебхук
+type WebhookEvent string
+
+const (
+	EventUserInZone        WebhookEvent = "user_in_zone"       //Пользователь попал в радиус инцидента
+	EventIncidentActivated WebhookEvent = "incident_activated" //Запланированный инцидент начал действовать
+	EventIncidentExpired   WebhookEvent = "incident_expired"   //Время действия инцидента истекло
+	EventIncidentResolved  WebhookEvent = "incident_resolved"  //Оператор завершил инцидент
+)
+
 type Webhook struct { //Структура вебхука который будет отправляться на оператору в случае попадания пользователя в радиус инцидента
-	UserID     string    `json:"user_id"`     //ID пользователя, который попал в радиус инцидента
-	IncidentID uuid.UUID `json:"incident_id"` //UUID инцидента, в который попал пользователь
-	DetectedAt time.Time `json:"detected_at"` //Время, в которое был замечен пользователь в радиусе инцидента
+	Event      WebhookEvent     `json:"event"`       //Тип события
+	UserID     string           `json:"user_id"`     //ID пользователя, который попал в радиус инцидента (пустой для событий жизненного цикла)
+	IncidentID uuid.UUID        `json:"incident_id"` //UUID инцидента, в который попал пользователь
+	Category   IncidentCategory `json:"category"`    //Категория инцидента
+	Severity   Severity         `json:"severity"`    //Уровень опасности инцидента
+	DetectedAt time.Time        `json:"detected_at"` //Время, в которое был замечен пользователь в радиусе инцидента
+
+	//тексты для человека на языке запроса, породившего вебхук, заполняются воркером перед отправкой
+	Lang         string `json:"lang,omitempty"`          //Язык текстов (ru, en), в очереди - язык запроса
+	Message      string `json:"message,omitempty"`       //Описание события
+	CategoryName string `json:"category_name,omitempty"` //Название категории инцидента
+	SeverityName string `json:"severity_name,omitempty"` //Название уровня опасности
+
+	TraceContext map[string]string `json:"trace_context,omitempty"` //trace context проверки, породившей вебхук (только внутри очереди, получателю не отправляется)
+	RequestID    string            `json:"request_id,omitempty"`    //ID запроса, породившего вебхук (внутри очереди, получателю уходит заголовком X-Request-ID)
+	TenantID     uuid.UUID         `json:"-"`                       //организация вебхука, определяется очередью, в которую он попал
+}
+
+// LiveUser - пользователь и его последняя известная позиция
+type LiveUser struct {
+	UserID    string    `json:"user_id"`   //ID пользователя
+	Latitude  float64   `json:"latitude"`  //Широта последней проверки
+	Longitude float64   `json:"longitude"` //Долгота последней проверки
+	SeenAt    time.Time `json:"seen_at"`   //Время последней проверки
+}
+
+// ZoneUsersResponse - пользователи, которые сейчас находятся в радиусе инцидента
+type ZoneUsersResponse struct {
+	IncidentID string     `json:"incident_id"`     //UUID
+	UserCount  int        `json:"user_count"`      //Сколько пользователей сейчас в зоне
+	Users      []LiveUser `json:"users,omitempty"` //Сами пользователи (не отдаются, если запрошено только количество)
+}
+
+// HeatmapCell - агрегированные проверки координат в одной ячейке сетки geohash
+type HeatmapCell struct {
+	Geohash        string  `json:"geohash"`               //Geohash ячейки
+	Latitude       float64 `json:"latitude"`              //Широта центра ячейки
+	Longitude      float64 `json:"longitude"`             //Долгота центра ячейки
+	Checks         int     `json:"checks"`                //Количество проверок в ячейке
+	UniqueUsers    int     `json:"unique_users"`          //Количество уникальных пользователей
+	InDangerChecks int     `json:"in_danger_checks"`      //Количество проверок, при которых пользователь был в опасной зоне
+	Approximate    bool    `json:"approximate,omitempty"` //true, если в ячейку попали свёрнутые часы: unique_users - оценка сверху
+}
+
+// GeoJSON FeatureCollection, в котором отдаётся тепловая карта (RFC 7946)
+type FeatureCollection struct {
+	Type     string    `json:"type"` //Всегда "FeatureCollection"
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string      `json:"type"` //Всегда "Feature"
+	Geometry   Geometry    `json:"geometry"`
+	Properties HeatmapCell `json:"properties"`
+}
+
+type Geometry struct {
+	Type        string         `json:"type"`        //"Polygon"
+	Coordinates [][][2]float64 `json:"coordinates"` //Кольца полигона, точки в порядке [долгота, широта]
+}
+
+// HealthStatus - состояние сервиса или одной из его зависимостей
+type HealthStatus string
+
+const (
+	HealthOK   HealthStatus = "ok"
+	HealthFail HealthStatus = "fail"
+)
+
+// ComponentHealth - результат проверки одной зависимости
+type ComponentHealth struct {
+	Status    HealthStatus `json:"status"`
+	LatencyMs int64        `json:"latency_ms"`
+	Error     string       `json:"error,omitempty"`
+	Details   any          `json:"details,omitempty"` //дополнительные данные проверки, например длина очереди
+}
+
+// HealthReport - ответ readiness пробы с разбивкой по зависимостям
+type HealthReport struct {
+	Status     HealthStatus               `json:"status"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+// RateLimit - лимит token bucket: Requests запросов за Period, с запасом до Burst запросов подряд
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Capacity - размер корзины, если Burst не задан - равен Requests
+func (l RateLimit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// RateLimitResult - результат попытки взять токен
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           //сколько запросов ещё можно сделать сразу
+	RetryAfter time.Duration //через сколько появится токен, если запрос отклонён
+	Reset      time.Duration //через сколько корзина наполнится полностью
 }
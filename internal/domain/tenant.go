@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTenantID - организация, которой принадлежат данные, созданные до появления мультиарендности,
+// и запросы, в которых организация не указана
+var DefaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// ErrTenantNotFound - организации с таким ID или slug нет
+var ErrTenantNotFound = NotFound(CodeTenantNotFound, "организация не найдена")
+
+// tenantSlugRe - slug попадает в ключи Redis и заголовок X-Tenant, поэтому ограничиваем его простыми символами
+var tenantSlugRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// Tenant - организация. Инциденты, проверки, ключи и вебхуки одной организации не видны другим.
+// Пустые настройки означают, что используется значение из конфигурации сервиса
+type Tenant struct {
+	ID                 uuid.UUID `json:"id"`
+	Slug               string    `json:"slug"`                           //короткое имя для заголовка X-Tenant и claim tenant в JWT
+	Name               string    `json:"name"`                           //название организации
+	WarningZone        *float64  `json:"warning_zone,omitempty"`         //своя зона предупреждения вокруг инцидентов, м
+	StatsWindowMinutes *int      `json:"stats_window_minutes,omitempty"` //своё окно статистики по умолчанию, мин
+	WebhookURL         *string   `json:"webhook_url,omitempty"`          //свой адрес для вебхуков
+	WebhookRetries     *int      `json:"webhook_retries,omitempty"`      //своё количество попыток доставки
+	WebhookTimeout     *int      `json:"webhook_timeout,omitempty"`      //свой таймаут доставки, сек
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// TenantRequest - тело запроса на создание или изменение организации
+type TenantRequest struct {
+	Slug               string   `json:"slug"`
+	Name               string   `json:"name"`
+	WarningZone        *float64 `json:"warning_zone"`
+	StatsWindowMinutes *int     `json:"stats_window_minutes"`
+	WebhookURL         *string  `json:"webhook_url"`
+	WebhookRetries     *int     `json:"webhook_retries"`
+	WebhookTimeout     *int     `json:"webhook_timeout"`
+}
+
+// Validate проверяет slug и то, что заданные настройки имеют смысл
+func (r *TenantRequest) Validate() error {
+	if !tenantSlugRe.MatchString(r.Slug) {
+		return Invalid(CodeInvalidSlug, "slug должен состоять из строчных латинских букв, цифр и дефиса (2-63 символа)").OnField("slug")
+	}
+	if r.Name == "" {
+		return Invalid(CodeTenantNameEmpty, "не указано название организации").OnField("name")
+	}
+	if r.WarningZone != nil && *r.WarningZone < 0 {
+		return Invalid(CodeInvalidWarningZone, "warning_zone не может быть отрицательной").OnField("warning_zone")
+	}
+	if r.StatsWindowMinutes != nil && *r.StatsWindowMinutes <= 0 {
+		return Invalid(CodeInvalidStatsWindow, "stats_window_minutes должно быть больше нуля").OnField("stats_window_minutes")
+	}
+	if r.WebhookRetries != nil && *r.WebhookRetries <= 0 {
+		return Invalid(CodeInvalidWebhookRetries, "webhook_retries должно быть больше нуля").OnField("webhook_retries")
+	}
+	if r.WebhookTimeout != nil && *r.WebhookTimeout <= 0 {
+		return Invalid(CodeInvalidWebhookTimeout, "webhook_timeout должно быть больше нуля").OnField("webhook_timeout")
+	}
+	return nil
+}
+
+// WarningZoneOr отдаёт зону предупреждения организации или значение по умолчанию
+func (t *Tenant) WarningZoneOr(def float64) float64 {
+	if t != nil && t.WarningZone != nil {
+		return *t.WarningZone
+	}
+	return def
+}
+
+// StatsWindowOr отдаёт окно статистики организации или значение по умолчанию
+func (t *Tenant) StatsWindowOr(def int) int {
+	if t != nil && t.StatsWindowMinutes != nil {
+		return *t.StatsWindowMinutes
+	}
+	return def
+}
+
+// WebhookURLOr отдаёт адрес вебхуков организации или значение по умолчанию
+func (t *Tenant) WebhookURLOr(def string) string {
+	if t != nil && t.WebhookURL != nil && *t.WebhookURL != "" {
+		return *t.WebhookURL
+	}
+	return def
+}
+
+// WebhookRetriesOr отдаёт количество попыток доставки организации или значение по умолчанию
+func (t *Tenant) WebhookRetriesOr(def int) int {
+	if t != nil && t.WebhookRetries != nil {
+		return *t.WebhookRetries
+	}
+	return def
+}
+
+// WebhookTimeoutOr отдаёт таймаут доставки организации или значение по умолчанию
+func (t *Tenant) WebhookTimeoutOr(def time.Duration) time.Duration {
+	if t != nil && t.WebhookTimeout != nil {
+		return time.Duration(*t.WebhookTimeout) * time.Second
+	}
+	return def
+}
+
+// tenantKey - ключ в контексте для организации, от имени которой выполняется запрос
+type tenantKey struct{}
+
+// WithTenant сохраняет в контексте организацию запроса, по ней репозитории ограничивают все запросы
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext достаёт организацию запроса, nil - организация не определена (CLI или фоновая задача)
+func TenantFromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantKey{}).(*Tenant)
+	return tenant
+}
+
+// TenantIDFromContext отдаёт ID организации запроса, если её нет - организацию по умолчанию.
+// Данные других организаций так не станут видны: запрос всегда ограничен ровно одной организацией
+func TenantIDFromContext(ctx context.Context) uuid.UUID {
+	if tenant := TenantFromContext(ctx); tenant != nil {
+		return tenant.ID
+	}
+	return DefaultTenantID
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LocationCheck - одна сохранённая проверка координат пользователя
+type LocationCheck struct {
+	ID          int64       `json:"id"`
+	UserID      string      `json:"user_id"`
+	Latitude    float64     `json:"lat"`
+	Longitude   float64     `json:"lon"`
+	IncidentIDs []uuid.UUID `json:"incident_ids"` //инциденты, в зону которых попала точка
+	CheckedAt   time.Time   `json:"checked_at"`
+}
+
+// WebhookDelivery - итог доставки вебхука после всех попыток
+type WebhookDelivery struct {
+	ID         int64        `json:"id"`
+	TenantID   uuid.UUID    `json:"-"`
+	Event      WebhookEvent `json:"event"`
+	UserID     string       `json:"user_id,omitempty"`
+	IncidentID uuid.UUID    `json:"incident_id"`
+	DetectedAt time.Time    `json:"detected_at"`
+	URL        string       `json:"url"`
+	Delivered  bool         `json:"delivered"`
+	Error      string       `json:"error,omitempty"` //последняя ошибка, если доставить не удалось
+	RequestID  string       `json:"request_id,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// UserDataExport - всё, что сервис хранит о пользователе в организации запроса
+type UserDataExport struct {
+	UserID     string             `json:"user_id"`
+	ExportedAt time.Time          `json:"exported_at"`
+	Checks     []*LocationCheck   `json:"location_checks"`
+	Deliveries []*WebhookDelivery `json:"webhook_deliveries"`
+}
+
+// UserErasure - что было удалено по запросу пользователя
+type UserErasure struct {
+	UserID         string `json:"user_id"`
+	Checks         int64  `json:"location_checks"`
+	Deliveries     int64  `json:"webhook_deliveries"`
+	QueuedWebhooks int64  `json:"queued_webhooks"` //ещё не отправленные вебхуки о пользователе
+	LivePosition   bool   `json:"live_position"`   //была ли сохранена последняя позиция пользователя
+}
@@ -0,0 +1,324 @@
+package i18n
+
+import (
+	"RedCollar/internal/domain"
+	"strconv"
+)
+
+// catalog - тексты сообщений по ключам. Ключ ошибки - её код из domain, аргументы подставляются в том же порядке,
+// что и в тексте ошибки в сервисе, поэтому у переводов одного кода должны совпадать глаголы форматирования
+var catalog = map[string]map[Lang]string{
+	domain.CodeInternal: {
+		RU: "внутренняя ошибка сервиса",
+		EN: "internal service error",
+	},
+	domain.CodeInvalidBody: {
+		RU: "невалидное тело запроса",
+		EN: "invalid request body",
+	},
+	domain.CodeInvalidID: {
+		RU: "невалидный ID: %q",
+		EN: "invalid ID: %q",
+	},
+	domain.CodeQueryNotNumber: {
+		RU: "%s должен быть числом",
+		EN: "%s must be a number",
+	},
+	domain.CodeQueryNotTime: {
+		RU: "%s должен быть в формате RFC3339",
+		EN: "%s must be an RFC3339 timestamp",
+	},
+	domain.CodeInvalidFormat: {
+		RU: "format должен быть json или csv",
+		EN: "format must be json or csv",
+	},
+	domain.CodeInvalidParameter: {
+		RU: "параметр %s не соответствует спецификации API",
+		EN: "parameter %s does not match the API specification",
+	},
+	domain.CodeSchemaViolation: {
+		RU: "поле %s не соответствует спецификации API",
+		EN: "field %s does not match the API specification",
+	},
+	domain.CodeUnsupportedMedia: {
+		RU: "тело запроса должно быть в формате %s",
+		EN: "the request body must be %s",
+	},
+
+	//инциденты
+	domain.CodeLatitudeRange: {
+		RU: "невалидная широта (должна быть в диапазоне от -90 до 90)",
+		EN: "invalid latitude (must be between -90 and 90)",
+	},
+	domain.CodeLongitudeRange: {
+		RU: "невалидная долгота (должна быть в диапазоне от -180 до 180)",
+		EN: "invalid longitude (must be between -180 and 180)",
+	},
+	domain.CodeCoordinatesMissing: {
+		RU: "не указаны координаты",
+		EN: "coordinates are missing",
+	},
+	domain.CodeTitleEmpty: {
+		RU: "заголовок не может быть пустым",
+		EN: "title must not be empty",
+	},
+	domain.CodeTitleTooLong: {
+		RU: "заголовок слишком длинный (максимум 255 символов)",
+		EN: "title is too long (255 characters max)",
+	},
+	domain.CodeDescriptionEmpty: {
+		RU: "описание не может быть пустым",
+		EN: "description must not be empty",
+	},
+	domain.CodeDescriptionTooLong: {
+		RU: "описание слишком длинное (максимум 255 символов)",
+		EN: "description is too long (255 characters max)",
+	},
+	domain.CodeUnknownCategory: {
+		RU: "неизвестная категория инцидента: %s",
+		EN: "unknown incident category: %s",
+	},
+	domain.CodeInvalidSeverity: {
+		RU: "невалидный уровень опасности (должен быть в диапазоне от 1 до 4)",
+		EN: "invalid severity (must be between 1 and 4)",
+	},
+	domain.CodeInvalidMinSeverity: {
+		RU: "невалидный минимальный уровень опасности (должен быть в диапазоне от 1 до 4)",
+		EN: "invalid minimum severity (must be between 1 and 4)",
+	},
+	domain.CodeUnknownStatus: {
+		RU: "неизвестный статус инцидента: %s",
+		EN: "unknown incident status: %s",
+	},
+	domain.CodeArchivedHidden: {
+		RU: "архивные инциденты скрыты из списка",
+		EN: "archived incidents are not listed",
+	},
+	domain.CodeInvalidSchedule: {
+		RU: "valid_until должен быть позже valid_from",
+		EN: "valid_until must be later than valid_from",
+	},
+	domain.CodeValidUntilPassed: {
+		RU: "valid_until уже прошёл",
+		EN: "valid_until is already in the past",
+	},
+	domain.CodeInitialStatus: {
+		RU: "при создании статус инцидента может быть только draft или active",
+		EN: "a new incident can only be draft or active",
+	},
+	domain.CodeInvalidVersion: {
+		RU: "невалидный номер версии",
+		EN: "invalid version number",
+	},
+	domain.CodeIncidentNotFound: {
+		RU: "инцидент с ID %s не найден",
+		EN: "incident %s not found",
+	},
+	domain.CodeVersionNotFound: {
+		RU: "версия %d инцидента %s не найдена",
+		EN: "version %d of incident %s not found",
+	},
+	domain.CodeStatusTransition: {
+		RU: "переход инцидента из статуса %s в статус %s запрещён",
+		EN: "incident cannot move from %s to %s",
+	},
+	domain.CodeReopenNotResolved: {
+		RU: "переоткрыть можно только завершённый инцидент, текущий статус: %s",
+		EN: "only a resolved incident can be reopened, current status: %s",
+	},
+	domain.CodeScheduleExpired: {
+		RU: "окно действия инцидента закончилось, сначала измените valid_until",
+		EN: "the incident window has ended, change valid_until first",
+	},
+	domain.CodeIncidentArchived: {
+		RU: "архивный инцидент нельзя изменить",
+		EN: "an archived incident cannot be changed",
+	},
+	domain.CodeStatusChanged: {
+		RU: "статус инцидента был изменён другим запросом",
+		EN: "the incident status was changed by another request",
+	},
+	domain.CodeIncidentNotActive: {
+		RU: "инцидент не действует (статус %s), пользователей в его зоне не отслеживаем",
+		EN: "the incident is not active (status %s), users in its zone are not tracked",
+	},
+
+	//статистика и пользователи в зоне
+	domain.CodeInvalidPeriod: {
+		RU: "конец периода должен быть позже начала",
+		EN: "the period end must be later than its start",
+	},
+	domain.CodeUnknownStatsMode: {
+		RU: "неизвестный режим статистики: %s (допустимо approx, exact)",
+		EN: "unknown stats mode: %s (approx or exact)",
+	},
+	domain.CodeUnknownBucket: {
+		RU: "неизвестный размер интервала: %s (допустимо minute, hour, day)",
+		EN: "unknown bucket size: %s (minute, hour or day)",
+	},
+	domain.CodeTooManyBuckets: {
+		RU: "слишком много интервалов (%d), максимум %d - увеличьте bucket или сократите период",
+		EN: "too many buckets (%d), at most %d - use a larger bucket or a shorter period",
+	},
+	domain.CodeInvalidPrecision: {
+		RU: "точность geohash должна быть в диапазоне от %d до %d",
+		EN: "geohash precision must be between %d and %d",
+	},
+	domain.CodeInvalidMaxAge: {
+		RU: "max_age должен быть в диапазоне от 1 секунды до %v",
+		EN: "max_age must be between 1 second and %v",
+	},
+
+	//доступ
+	domain.CodeAPIKeyRequired: {
+		RU: "укажите заголовок с валидным API ключом",
+		EN: "provide a valid API key header",
+	},
+	domain.CodeInvalidAPIKey: {
+		RU: "невалидный API ключ",
+		EN: "invalid API key",
+	},
+	domain.CodeMissingScope: {
+		RU: "у API ключа нет права %s",
+		EN: "the API key lacks the %s scope",
+	},
+	domain.CodeTokenRequired: {
+		RU: "укажите заголовок Authorization с токеном пользователя",
+		EN: "provide an Authorization header with the user token",
+	},
+	domain.CodeInvalidToken: {
+		RU: "невалидный токен пользователя",
+		EN: "invalid user token",
+	},
+	domain.CodeTenantNotFound: {
+		RU: "организация не найдена",
+		EN: "tenant not found",
+	},
+	domain.CodeTenantMismatch: {
+		RU: "API ключ не относится к организации %s",
+		EN: "the API key does not belong to tenant %s",
+	},
+	domain.CodeRateLimited: {
+		RU: "слишком много запросов, повторите позже",
+		EN: "too many requests, retry later",
+	},
+
+	//администрирование
+	domain.CodeTenantExists: {
+		RU: "организация с таким slug уже существует",
+		EN: "a tenant with this slug already exists",
+	},
+	domain.CodeInvalidSlug: {
+		RU: "slug должен состоять из строчных латинских букв, цифр и дефиса (2-63 символа)",
+		EN: "slug must consist of lowercase latin letters, digits and hyphens (2-63 characters)",
+	},
+	domain.CodeTenantNameEmpty: {
+		RU: "не указано название организации",
+		EN: "tenant name is missing",
+	},
+	domain.CodeInvalidWarningZone: {
+		RU: "warning_zone не может быть отрицательной",
+		EN: "warning_zone must not be negative",
+	},
+	domain.CodeInvalidStatsWindow: {
+		RU: "stats_window_minutes должно быть больше нуля",
+		EN: "stats_window_minutes must be positive",
+	},
+	domain.CodeInvalidWebhookRetries: {
+		RU: "webhook_retries должно быть больше нуля",
+		EN: "webhook_retries must be positive",
+	},
+	domain.CodeInvalidWebhookTimeout: {
+		RU: "webhook_timeout должно быть больше нуля",
+		EN: "webhook_timeout must be positive",
+	},
+	domain.CodeAPIKeyNotFound: {
+		RU: "API ключ не найден",
+		EN: "API key not found",
+	},
+	domain.CodeInvalidKeyName: {
+		RU: "имя ключа должно быть от 1 до %d символов",
+		EN: "key name must be 1 to %d characters long",
+	},
+	domain.CodeScopesEmpty: {
+		RU: "ключу нужно выдать хотя бы одно право",
+		EN: "the key needs at least one scope",
+	},
+	domain.CodeUnknownScope: {
+		RU: "неизвестное право %q",
+		EN: "unknown scope %q",
+	},
+	domain.CodeScopeNotGrantable: {
+		RU: "право %q нельзя выдать API ключу",
+		EN: "scope %q cannot be granted to an API key",
+	},
+	domain.CodeScopeNotHeld: {
+		RU: "нельзя выдать право %q, которого нет у текущего ключа",
+		EN: "cannot grant scope %q that the current key does not have",
+	},
+	domain.CodeExpiresInPast: {
+		RU: "expires_at должен быть в будущем",
+		EN: "expires_at must be in the future",
+	},
+	domain.CodeInvalidAuditResult: {
+		RU: "result должен быть success или failure",
+		EN: "result must be success or failure",
+	},
+	domain.CodeNegativePagination: {
+		RU: "limit и offset не могут быть отрицательными",
+		EN: "limit and offset must not be negative",
+	},
+	domain.CodeInvalidUserID: {
+		RU: "некорректный user_id",
+		EN: "invalid user_id",
+	},
+
+	//вебхуки: описание события и названия категорий и уровней опасности
+	eventKey(domain.EventUserInZone): {
+		RU: "Пользователь находится в зоне инцидента",
+		EN: "A user is inside the incident zone",
+	},
+	eventKey(domain.EventIncidentActivated): {
+		RU: "Инцидент начал действовать",
+		EN: "The incident is now active",
+	},
+	eventKey(domain.EventIncidentExpired): {
+		RU: "Время действия инцидента истекло",
+		EN: "The incident has expired",
+	},
+	eventKey(domain.EventIncidentResolved): {
+		RU: "Инцидент завершён",
+		EN: "The incident has been resolved",
+	},
+	categoryKey(domain.CategoryFire):       {RU: "Пожар", EN: "Fire"},
+	categoryKey(domain.CategoryFlood):      {RU: "Наводнение", EN: "Flood"},
+	categoryKey(domain.CategoryChemical):   {RU: "Химическая опасность", EN: "Chemical hazard"},
+	categoryKey(domain.CategoryRoad):       {RU: "Дорожный инцидент", EN: "Road incident"},
+	categoryKey(domain.CategoryEarthquake): {RU: "Землетрясение", EN: "Earthquake"},
+	categoryKey(domain.CategoryStorm):      {RU: "Шторм", EN: "Storm"},
+	categoryKey(domain.CategoryOther):      {RU: "Другое", EN: "Other"},
+	severityKey(domain.SeverityLow):        {RU: "Незначительный", EN: "Low"},
+	severityKey(domain.SeverityMedium):     {RU: "Средний", EN: "Medium"},
+	severityKey(domain.SeverityHigh):       {RU: "Высокий", EN: "High"},
+	severityKey(domain.SeverityCritical):   {RU: "Критический", EN: "Critical"},
+}
+
+func eventKey(e domain.WebhookEvent) string {
+	return "event." + string(e)
+}
+
+func categoryKey(c domain.IncidentCategory) string {
+	return "category." + string(c)
+}
+
+func severityKey(s domain.Severity) string {
+	return "severity." + strconv.Itoa(int(s))
+}
+
+// Localize заполняет текстовые поля вебхука на указанном языке, для неизвестных значений поля остаются пустыми
+func Localize(webhook *domain.Webhook, lang Lang) {
+	webhook.Lang = string(lang)
+	webhook.Message, _ = Text(lang, eventKey(webhook.Event))
+	webhook.CategoryName, _ = Text(lang, categoryKey(webhook.Category))
+	webhook.SeverityName, _ = Text(lang, severityKey(webhook.Severity))
+}
@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lang - язык сообщений API
+type Lang string
+
+const (
+	RU Lang = "ru"
+	EN Lang = "en"
+
+	Default = RU //язык, на котором написаны тексты в коде сервиса
+)
+
+// ParseLang проверяет язык из конфигурации
+func ParseLang(s string) (Lang, error) {
+	switch lang := Lang(strings.ToLower(strings.TrimSpace(s))); lang {
+	case RU, EN:
+		return lang, nil
+	}
+	return "", fmt.Errorf("неизвестный язык %q (допустимо ru, en)", s)
+}
+
+// Negotiate выбирает язык по заголовку Accept-Language (RFC 9110): берётся поддерживаемый язык с наибольшим q,
+// региональные варианты вроде en-US сводятся к основному языку. Если подходящего нет - отдаётся def
+func Negotiate(header string, def Lang) Lang {
+	type candidate struct {
+		lang Lang
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if raw, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		lang := Lang(base)
+		if base == "*" {
+			lang = def
+		}
+		if lang == RU || lang == EN {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return def
+	}
+	//при равном q выигрывает язык, указанный раньше
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// langKey - ключ языка в контексте
+type langKey struct{}
+
+// WithLang сохраняет в контексте язык, на котором отвечать клиенту и писать вебхуки, порождённые запросом
+func WithLang(ctx context.Context, lang Lang) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// FromContext достаёт язык из контекста, если его нет - Default
+func FromContext(ctx context.Context) Lang {
+	if lang, ok := ctx.Value(langKey{}).(Lang); ok && lang != "" {
+		return lang
+	}
+	return Default
+}
+
+// Text собирает сообщение из каталога по ключу. Если для языка нет перевода, берётся русский текст,
+// а если ключа нет в каталоге вовсе - ok = false и вызывающий использует свой текст
+func Text(lang Lang, key string, args ...any) (string, bool) {
+	translations, ok := catalog[key]
+	if !ok {
+		return "", false
+	}
+	format, ok := translations[lang]
+	if !ok {
+		format = translations[Default]
+	}
+	return fmt.Sprintf(format, args...), true
+}
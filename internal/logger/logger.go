@@ -0,0 +1,70 @@
+// Package logger настраивает структурированные JSON логи (log/slog) для всего сервиса
+package logger
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// level общий для всех логгеров, чтобы уровень можно было поменять после чтения конфига
+var level = new(slog.LevelVar)
+
+// Init делает JSON логгер логгером по умолчанию, до вызова SetLevel пишутся сообщения уровня info и выше
+func Init() {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(contextHandler{Handler: handler}))
+}
+
+// SetLevel меняет уровень логирования: debug, info, warn или error
+func SetLevel(name string) error {
+	parsed, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(parsed)
+	return nil
+}
+
+// ParseLevel переводит название уровня из конфига в slog.Level
+func ParseLevel(name string) (slog.Level, error) {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return 0, fmt.Errorf("неизвестный уровень логирования %q", name)
+	}
+	return parsed, nil
+}
+
+// IsDebug сообщает, включены ли отладочные логи
+func IsDebug() bool {
+	return level.Level() <= slog.LevelDebug
+}
+
+// contextHandler дописывает в каждую запись ID запроса и трейса из контекста,
+// поэтому в коде достаточно логировать через slog.*Context(ctx, ...)
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := domain.RequestIDFromContext(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,142 @@
+// Package metrics описывает метрики сервиса для Prometheus, которые отдаются на GET /metrics
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace - общий префикс всех метрик сервиса
+const namespace = "notifier"
+
+var (
+	// HTTPRequests - количество HTTP запросов по маршруту и статус коду
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Количество HTTP запросов",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration - время обработки HTTP запросов по маршруту
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Время обработки HTTP запросов",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// LocationChecks - количество проверок координат по результату: danger или safe
+	LocationChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "location_checks_total",
+		Help:      "Количество проверок координат по результату",
+	}, []string{"result"})
+
+	// CacheRequests - обращения к кэшу инцидентов: hit или miss
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "incident_cache_requests_total",
+		Help:      "Обращения к кэшу инцидентов по результату",
+	}, []string{"result"})
+
+	// WebhookDeliveries - итог доставки вебхуков после всех ретраев: success или failure
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Количество доставленных и недоставленных вебхуков",
+	}, []string{"result"})
+
+	// WebhookRetries - количество повторных попыток отправки вебхука
+	WebhookRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_delivery_retries_total",
+		Help:      "Количество повторных попыток отправки вебхуков",
+	})
+
+	// RateLimited - количество запросов, отклонённых rate limiter, по маршруту
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_rate_limited_total",
+		Help:      "Количество запросов, отклонённых по лимиту частоты",
+	}, []string{"route"})
+
+	// LocationChecksPruned - количество проверок координат, удалённых по сроку хранения пачками (без удалённых секций)
+	LocationChecksPruned = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "location_checks_pruned_total",
+		Help:      "Количество проверок координат, удалённых по сроку хранения",
+	})
+)
+
+// Значения лейблов, чтобы не размазывать строки по сервису
+const (
+	ResultDanger  = "danger"
+	ResultSafe    = "safe"
+	ResultHit     = "hit"
+	ResultMiss    = "miss"
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// RegisterQueueDepth регистрирует метрику длины очереди вебхуков, длина запрашивается при каждом сборе метрик
+func RegisterQueueDepth(depth func(ctx context.Context) (int64, error)) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "webhook_queue_depth",
+		Help:      "Количество вебхуков, ожидающих отправки",
+	}, func() float64 {
+		//сбор метрик не должен зависнуть из-за недоступного redis
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		n, err := depth(ctx)
+		if err != nil {
+			return -1
+		}
+		return float64(n)
+	})
+}
+
+// RegisterPgxPool регистрирует метрики пула соединений с postgres
+func RegisterPgxPool(stat func() *pgxpool.Stat) {
+	prometheus.MustRegister(&pgxPoolCollector{stat: stat})
+}
+
+var (
+	poolAcquiredConns = prometheus.NewDesc(namespace+"_pgx_pool_acquired_conns", "Соединения, занятые запросами", nil, nil)
+	poolIdleConns     = prometheus.NewDesc(namespace+"_pgx_pool_idle_conns", "Свободные соединения", nil, nil)
+	poolTotalConns    = prometheus.NewDesc(namespace+"_pgx_pool_total_conns", "Все открытые соединения", nil, nil)
+	poolMaxConns      = prometheus.NewDesc(namespace+"_pgx_pool_max_conns", "Максимальный размер пула", nil, nil)
+	poolAcquires      = prometheus.NewDesc(namespace+"_pgx_pool_acquires_total", "Количество получений соединения из пула", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc(namespace+"_pgx_pool_empty_acquires_total", "Получения соединения, которым пришлось ждать", nil, nil)
+	poolAcquireWait   = prometheus.NewDesc(namespace+"_pgx_pool_acquire_duration_seconds_total", "Суммарное время ожидания соединения", nil, nil)
+)
+
+// pgxPoolCollector читает статистику пула в момент сбора метрик
+type pgxPoolCollector struct {
+	stat func() *pgxpool.Stat
+}
+
+func (c *pgxPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredConns
+	ch <- poolIdleConns
+	ch <- poolTotalConns
+	ch <- poolMaxConns
+	ch <- poolAcquires
+	ch <- poolEmptyAcquires
+	ch <- poolAcquireWait
+}
+
+func (c *pgxPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireWait, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrAPIKeyNotFound - ключа с таким префиксом или ID нет
+var ErrAPIKeyNotFound = domain.NotFound(domain.CodeAPIKeyNotFound, "API ключ не найден")
+
+// LastUsedPrecision - last_used_at обновляется не чаще раза в минуту, чтобы не писать в базу на каждый запрос
+const LastUsedPrecision = time.Minute
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *domain.APIKey, hash []byte) error
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, []byte, error)
+	ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+	TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+const apiKeyColumns = `id, tenant_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row pgx.Row, extra ...any) (*domain.APIKey, error) {
+	var key domain.APIKey
+	var scopes []string
+	dest := append([]any{&key.ID, &key.TenantID, &key.Name, &key.Prefix, &scopes, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	key.Scopes = make([]domain.Scope, len(scopes))
+	for idx, scope := range scopes {
+		key.Scopes[idx] = domain.Scope(scope)
+	}
+	return &key, nil
+}
+
+// CreateAPIKey сохраняет новый ключ в организации key.TenantID, ID и время создания проставляет база
+func (r *PostgresStorage) CreateAPIKey(ctx context.Context, key *domain.APIKey, hash []byte) error {
+	scopes := make([]string, len(key.Scopes))
+	for idx, scope := range key.Scopes {
+		scopes[idx] = string(scope)
+	}
+	query := `INSERT INTO api_keys (tenant_id, name, prefix, key_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	if err := r.conn.QueryRow(ctx, query, key.TenantID, key.Name, key.Prefix, hash, scopes, key.ExpiresAt).Scan(&key.ID, &key.CreatedAt); err != nil {
+		return fmt.Errorf("ошибка сохранения API ключа: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKeyByPrefix находит ключ по открытому префиксу и отдаёт вместе с хэшем для сравнения.
+// Поиск идёт по всем организациям: организация запроса как раз определяется по найденному ключу
+func (r *PostgresStorage) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, []byte, error) {
+	var hash []byte
+	key, err := scanAPIKey(r.conn.QueryRow(ctx, `SELECT `+apiKeyColumns+`, key_hash FROM api_keys WHERE prefix = $1`, prefix), &hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения API ключа: %w", err)
+	}
+	return key, hash, nil
+}
+
+// ListAPIKeys отдаёт все ключи организации из контекста, включая отозванные, чтобы было видно кто и когда ими пользовался
+func (r *PostgresStorage) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE tenant_id = $1 ORDER BY created_at DESC`
+	rows, err := r.conn.Query(ctx, query, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения API ключей: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]*domain.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения API ключа: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey отзывает ключ организации из контекста, повторный отзыв не меняет время первого
+func (r *PostgresStorage) RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1 AND tenant_id = $3`
+	tag, err := r.conn.Exec(ctx, query, id, at, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва API ключа: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey запоминает время последнего использования ключа
+func (r *PostgresStorage) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = $2 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)`
+	_, err := r.conn.Exec(ctx, query, id, at, at.Add(-LastUsedPrecision))
+	return err
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type AuditRepository interface {
+	SaveAudit(ctx context.Context, entry *domain.AuditEntry) error
+	ListAudit(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error)
+}
+
+const auditColumns = `id, tenant_id, actor_key_id, actor, method, route, incident_id, target, body_sha256, ip, status, request_id, created_at`
+
+func scanAuditEntry(row pgx.Row) (*domain.AuditEntry, error) {
+	var e domain.AuditEntry
+	err := row.Scan(&e.ID, &e.TenantID, &e.ActorKeyID, &e.Actor, &e.Method, &e.Route, &e.IncidentID, &e.Target, &e.BodySHA256, &e.IP, &e.Status, &e.RequestID, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// SaveAudit добавляет запись в журнал организации entry.TenantID, ID и время проставляет база
+func (r *PostgresStorage) SaveAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	query := `
+        INSERT INTO audit_log (tenant_id, actor_key_id, actor, method, route, incident_id, target, body_sha256, ip, status, request_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING id, created_at`
+	err := r.conn.QueryRow(ctx, query,
+		entry.TenantID, entry.ActorKeyID, entry.Actor, entry.Method, entry.Route, entry.IncidentID, entry.Target, entry.BodySHA256, entry.IP, entry.Status, entry.RequestID,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в журнал действий: %w", err)
+	}
+	return nil
+}
+
+// ListAudit отдаёт записи журнала организации из контекста, новые первыми
+func (r *PostgresStorage) ListAudit(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+	query := `SELECT ` + auditColumns + ` FROM audit_log
+        WHERE tenant_id = $1
+        AND ($2::uuid IS NULL OR actor_key_id = $2)
+        AND ($3::uuid IS NULL OR incident_id = $3)
+        AND ($4 = '' OR route = $4)
+        AND ($5 = '' OR method = $5)
+        AND ($6 = '' OR ($6 = 'success') = (status BETWEEN 200 AND 299))
+        AND ($7::timestamptz IS NULL OR created_at >= $7)
+        AND ($8::timestamptz IS NULL OR created_at < $8)
+        AND (cardinality($9::text[]) = 0 OR target = ANY($9))
+        ORDER BY created_at DESC, id DESC
+        LIMIT $10 OFFSET $11`
+	rows, err := r.conn.Query(ctx, query, domain.TenantIDFromContext(ctx), filter.ActorKeyID, filter.IncidentID,
+		filter.Route, filter.Method, string(filter.Result), from, to, filter.Targets, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения журнала действий: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*domain.AuditEntry, 0)
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения записи журнала: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"RedCollar/internal/config"
+	"RedCollar/internal/domain"
+	"RedCollar/internal/migrator"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newTestStorage подключается к базе из TEST_DATABASE_URL и применяет миграции.
+// Без переменной тесты с postgres пропускаются
+func newTestStorage(t *testing.T) *PostgresStorage {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL не задан, тесты с postgres пропущены")
+	}
+	m, err := migrator.New(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewPostgresConnection(context.Background(), &config.Config{PostgresDSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// newTestTenant заводит организацию со случайным slug и отдаёт контекст запроса от её имени
+func newTestTenant(t *testing.T, db *PostgresStorage) context.Context {
+	t.Helper()
+	tenant, err := db.CreateTenant(context.Background(), domain.TenantRequest{Slug: "t-" + uuid.NewString()[:8], Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return domain.WithTenant(context.Background(), tenant)
+}
+
+func assertNotFound(t *testing.T, what string, err error) {
+	t.Helper()
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("%s: ожидали not found, получили %v", what, err)
+	}
+}
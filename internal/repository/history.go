@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// querier - общие методы пула и транзакции, чтобы хелперы работали и внутри транзакции, и без неё
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// inTx выполняет fn в транзакции: коммитит при успехе и откатывает при ошибке
+func (r *PostgresStorage) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := r.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия транзакции: %w", err)
+	}
+	//после успешного коммита Rollback ничего не делает, так что его можно безопасно вызывать всегда
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// lockIncident читает инцидент организации из контекста с блокировкой строки до конца транзакции, чтобы версии не перемешались.
+// Инцидент чужой организации считается ненайденным, поэтому следующий за ним UPDATE по id уже безопасен
+func lockIncident(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*domain.Incident, error) {
+	query := `SELECT ` + incidentColumns + ` FROM incidents WHERE id = $1 AND tenant_id = $2 FOR UPDATE`
+	incident, err := scanIncident(tx.QueryRow(ctx, query, id, domain.TenantIDFromContext(ctx)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.NotFound(domain.CodeIncidentNotFound, "инцидент с ID %s не найден", id).Wrap(err)
+	}
+	return incident, err
+}
+
+// saveVersion записывает очередную версию инцидента, номер версии считается от последней записанной
+func saveVersion(ctx context.Context, q querier, action domain.IncidentAction, old, new *domain.Incident, restoredFrom *int) error {
+	query := `
+        INSERT INTO incident_versions (incident_id, version, action, actor, old_value, new_value, restored_from)
+        VALUES ($1, COALESCE((SELECT MAX(version) FROM incident_versions WHERE incident_id = $1), 0) + 1, $2, $3, $4, $5, $6)`
+
+	_, err := q.Exec(ctx, query, new.ID, action, domain.ActorFromContext(ctx), old, new, restoredFrom)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения версии инцидента: %w", err)
+	}
+	return nil
+}
+
+// versionTenantFilter - в истории нет своей колонки организации, поэтому проверяем, что инцидент ($1) принадлежит организации ($2)
+const versionTenantFilter = `AND EXISTS (SELECT 1 FROM incidents WHERE id = $1 AND tenant_id = $2)`
+
+const versionColumns = `incident_id, version, action, actor, changed_at, old_value, new_value, restored_from`
+
+func scanVersion(row pgx.Row) (*domain.IncidentVersion, error) {
+	var v domain.IncidentVersion
+	if err := row.Scan(&v.IncidentID, &v.Version, &v.Action, &v.Actor, &v.ChangedAt, &v.OldValue, &v.NewValue, &v.RestoredFrom); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetHistory отдаёт все версии инцидента, начиная с последней
+func (r *PostgresStorage) GetHistory(ctx context.Context, id uuid.UUID) ([]*domain.IncidentVersion, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
+	}
+
+	query := `SELECT ` + versionColumns + ` FROM incident_versions WHERE incident_id = $1 ` + versionTenantFilter + ` ORDER BY version DESC`
+	rows, err := r.conn.Query(ctx, query, id, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истории инцидента: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]*domain.IncidentVersion, 0)
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения версии инцидента: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов запроса: %w", err)
+	}
+	return versions, nil
+}
+
+// GetVersion отдаёт конкретную версию инцидента
+func (r *PostgresStorage) GetVersion(ctx context.Context, id uuid.UUID, version int) (*domain.IncidentVersion, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
+	}
+
+	query := `SELECT ` + versionColumns + ` FROM incident_versions WHERE incident_id = $1 ` + versionTenantFilter + ` AND version = $3`
+	v, err := scanVersion(r.conn.QueryRow(ctx, query, id, domain.TenantIDFromContext(ctx), version))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NotFound(domain.CodeVersionNotFound, "версия %d инцидента %s не найдена", version, id).Wrap(err)
+		}
+		return nil, fmt.Errorf("ошибка получения версии инцидента: %w", err)
+	}
+	return v, nil
+}
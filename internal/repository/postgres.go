@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -15,14 +16,34 @@ import (
 type IncidentRepository interface {
 	Create(ctx context.Context, incident *domain.Incident) (uuid.UUID, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*domain.Incident, error)
-	Get(ctx context.Context, lat float64, long float64, limit, offset int, extraRadius float64) ([]*domain.Incident, error)
+	Get(ctx context.Context, lat float64, long float64, limit, offset int, extraRadius float64, filter domain.IncidentFilter) ([]*domain.Incident, error)
 	Update(ctx context.Context, incident *domain.Incident) error
-	Delete(ctx context.Context, id uuid.UUID) error
+	SetStatus(ctx context.Context, id uuid.UUID, from, to domain.IncidentStatus) (*domain.Incident, error)
 	SaveCheck(ctx context.Context, userID string, lat, lon float64, incidentIDs []uuid.UUID) error
-	GetStats(ctx context.Context, minutes int) ([]domain.StatisticResponse, error)
+	GetStats(ctx context.Context, q domain.StatsQuery) ([]domain.StatisticResponse, error)
+	GetStatsSeries(ctx context.Context, q domain.StatsQuery) (map[string][]domain.StatsPoint, error)
+	GetHeatmap(ctx context.Context, from, to time.Time, cellLat, cellLon float64) ([]domain.HeatmapCell, error)
+	ActivateScheduled(ctx context.Context, now time.Time) ([]*domain.Incident, error)
+	ExpireScheduled(ctx context.Context, now time.Time) ([]*domain.Incident, error)
+	Restore(ctx context.Context, incident *domain.Incident, fromVersion int) error
+	GetHistory(ctx context.Context, id uuid.UUID) ([]*domain.IncidentVersion, error)
+	GetVersion(ctx context.Context, id uuid.UUID, version int) (*domain.IncidentVersion, error)
 	Close()
 }
 
+// incidentColumns - общий список колонок инцидента, порядок должен совпадать с порядком полей в scanIncident
+const incidentColumns = `id, title, description, category, severity, lat, lon, radius_meters, status, valid_from, valid_until, created_at, tenant_id`
+
+// scanIncident читает одну строку из результата запроса в структуру инцидента
+func scanIncident(row pgx.Row) (*domain.Incident, error) {
+	var i domain.Incident
+	err := row.Scan(&i.ID, &i.Title, &i.Description, &i.Category, &i.Severity, &i.Latitude, &i.Longitude, &i.RadiusMeters, &i.Status, &i.ValidFrom, &i.ValidUntil, &i.CreatedAt, &i.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
 type PostgresStorage struct {
 	conn *pgxpool.Pool
 }
@@ -33,7 +54,14 @@ func NewPostgresConnection(ctx context.Context, cfg *config.Config) (*PostgresSt
 		return nil, fmt.Errorf("строка подключения к базе данных не установлена")
 	}
 
-	pool, err := pgxpool.New(ctx, dsn)
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("некорректная строка подключения к базе данных: %w", err)
+	}
+	//каждый запрос к postgres попадает в трейс отдельным спаном
+	poolCfg.ConnConfig.Tracer = queryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания пула соединений с базой данных: %w", err)
 	}
@@ -45,27 +73,44 @@ func NewPostgresConnection(ctx context.Context, cfg *config.Config) (*PostgresSt
 	return &PostgresStorage{conn: pool}, nil
 }
 
+// Ping проверяет, что postgres доступен и в пуле можно получить соединение
+func (r *PostgresStorage) Ping(ctx context.Context) error {
+	return r.conn.Ping(ctx)
+}
+
+// Stat отдаёт статистику пула соединений для метрик
+func (r *PostgresStorage) Stat() *pgxpool.Stat {
+	return r.conn.Stat()
+}
+
 func (r *PostgresStorage) Close() {
 	if r.conn != nil {
 		r.conn.Close()
 	}
 }
 
+// Create создаёт инцидент и в той же транзакции записывает первую версию в историю изменений
 func (r *PostgresStorage) Create(ctx context.Context, incident *domain.Incident) (uuid.UUID, error) {
 	if r.conn == nil {
 		return uuid.Nil, fmt.Errorf("подключение к базе данных не инициализировано")
 	}
 
-	var id uuid.UUID
 	query := `
-        INSERT INTO incidents (title, description, lat, lon, radius_meters, is_active, created_at) 
-        VALUES ($1, $2, $3, $4, $5, $6, $7) 
-        RETURNING id`
-
-	err := r.conn.QueryRow(ctx, query,
-		incident.Title, incident.Description, incident.Latitude, incident.Longitude, incident.RadiusMeters, incident.IsActive, incident.CreatedAt,
-	).Scan(&id)
+        INSERT INTO incidents (title, description, category, severity, lat, lon, radius_meters, status, valid_from, valid_until, created_at, tenant_id) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) 
+        RETURNING ` + incidentColumns
 
+	var id uuid.UUID
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		created, err := scanIncident(tx.QueryRow(ctx, query,
+			incident.Title, incident.Description, incident.Category, incident.Severity, incident.Latitude, incident.Longitude, incident.RadiusMeters, incident.Status, incident.ValidFrom, incident.ValidUntil, incident.CreatedAt, domain.TenantIDFromContext(ctx),
+		))
+		if err != nil {
+			return err
+		}
+		id = created.ID
+		return saveVersion(ctx, tx, domain.ActionCreate, nil, created, nil)
+	})
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("ошибка создания записи в базе данных: %w", err)
 	}
@@ -77,51 +122,98 @@ func (r *PostgresStorage) GetByID(ctx context.Context, id uuid.UUID) (*domain.In
 		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
 	}
 
-	var incident domain.Incident
-	query := `SELECT id, title, description, lat, lon, radius_meters, is_active, created_at FROM incidents WHERE id = $1`
-
-	err := r.conn.QueryRow(ctx, query, id).Scan(
-		&incident.ID, &incident.Title, &incident.Description, &incident.Latitude, &incident.Longitude, &incident.RadiusMeters, &incident.IsActive, &incident.CreatedAt,
-	)
+	//инцидент чужой организации для запроса не существует
+	query := `SELECT ` + incidentColumns + ` FROM incidents WHERE id = $1 AND tenant_id = $2`
 
+	incident, err := scanIncident(r.conn.QueryRow(ctx, query, id, domain.TenantIDFromContext(ctx)))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("инцидент с ID %s не найден: %w", id.String(), err)
+			return nil, domain.NotFound(domain.CodeIncidentNotFound, "инцидент с ID %s не найден", id).Wrap(err)
 		}
 		return nil, fmt.Errorf("ошибка получения записи по ID из базы данных: %w", err)
 	}
-	return &incident, nil
+	return incident, nil
 }
 
+// Update перезаписывает инцидент и сохраняет в историю состояние до и после изменения
 func (r *PostgresStorage) Update(ctx context.Context, incident *domain.Incident) error {
 	if r.conn == nil {
 		return fmt.Errorf("подключение к базе данных не инициализировано")
 	}
-
-	query := `UPDATE incidents SET title=$1, description=$2, lat=$3, lon=$4, radius_meters=$5, is_active=$6 WHERE id=$7`
-	_, err := r.conn.Exec(ctx, query, incident.Title, incident.Description, incident.Latitude, incident.Longitude, incident.RadiusMeters, incident.IsActive, incident.ID)
-	if err != nil {
+	if err := r.update(ctx, incident, domain.ActionUpdate, nil); err != nil {
 		return fmt.Errorf("ошибка обновления записи в базе данных: %w", err)
 	}
 	return nil
 }
 
-func (r *PostgresStorage) Delete(ctx context.Context, id uuid.UUID) error {
+// Restore перезаписывает инцидент состоянием из версии fromVersion, в истории это отдельная версия с action = restore
+func (r *PostgresStorage) Restore(ctx context.Context, incident *domain.Incident, fromVersion int) error {
 	if r.conn == nil {
 		return fmt.Errorf("подключение к базе данных не инициализировано")
 	}
+	if err := r.update(ctx, incident, domain.ActionRestore, &fromVersion); err != nil {
+		return fmt.Errorf("ошибка восстановления версии инцидента: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresStorage) update(ctx context.Context, incident *domain.Incident, action domain.IncidentAction, restoredFrom *int) error {
+	query := `
+        UPDATE incidents SET title=$1, description=$2, category=$3, severity=$4, lat=$5, lon=$6, radius_meters=$7, status=$8, valid_from=$9, valid_until=$10 
+        WHERE id=$11 
+        RETURNING ` + incidentColumns
+
+	return r.inTx(ctx, func(tx pgx.Tx) error {
+		old, err := lockIncident(ctx, tx, incident.ID)
+		if err != nil {
+			return err
+		}
+		updated, err := scanIncident(tx.QueryRow(ctx, query,
+			incident.Title, incident.Description, incident.Category, incident.Severity, incident.Latitude, incident.Longitude, incident.RadiusMeters, incident.Status, incident.ValidFrom, incident.ValidUntil, incident.ID,
+		))
+		if err != nil {
+			return err
+		}
+		return saveVersion(ctx, tx, action, old, updated, restoredFrom)
+	})
+}
+
+// ErrStatusChanged возвращается, когда статус инцидента успели поменять между проверкой перехода в сервисе и записью
+var ErrStatusChanged = domain.Conflict(domain.CodeStatusChanged, "статус инцидента был изменён другим запросом")
 
-	query := `UPDATE incidents SET is_active = false WHERE id = $1`
-	_, err := r.conn.Exec(ctx, query, id)
+// SetStatus переводит инцидент из статуса from в статус to и сохраняет изменение в историю.
+// Допустимость перехода проверяет сервис, а здесь мы только убеждаемся, что статус не поменялся с момента проверки
+func (r *PostgresStorage) SetStatus(ctx context.Context, id uuid.UUID, from, to domain.IncidentStatus) (*domain.Incident, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
+	}
+
+	query := `UPDATE incidents SET status = $2 WHERE id = $1 RETURNING ` + incidentColumns
+
+	var updated *domain.Incident
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		old, err := lockIncident(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if old.Status != from {
+			return ErrStatusChanged
+		}
+		updated, err = scanIncident(tx.QueryRow(ctx, query, id, to))
+		if err != nil {
+			return err
+		}
+		return saveVersion(ctx, tx, domain.ActionStatus, old, updated, nil)
+	})
 	if err != nil {
-		return fmt.Errorf("ошибка удаления записи в базе данных: %w", err)
+		return nil, fmt.Errorf("ошибка изменения статуса инцидента: %w", err)
 	}
-	return nil
+	return updated, nil
 }
 
 // Метод отвечает за то, чтобы относительно точки(полученной от пользователя или оператора) найти список ицидентов
-// отсортированный по удалению
-func (r *PostgresStorage) Get(ctx context.Context, lat float64, long float64, limit, offset int, extraRadius float64) ([]*domain.Incident, error) {
+// отсортированный сначала по уровню опасности, а затем по удалению
+func (r *PostgresStorage) Get(ctx context.Context, lat float64, long float64, limit, offset int, extraRadius float64, filter domain.IncidentFilter) ([]*domain.Incident, error) {
 	if r.conn == nil {
 		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
 	}
@@ -130,30 +222,51 @@ func (r *PostgresStorage) Get(ctx context.Context, lat float64, long float64, li
 	//Запрос с формулой Гаверсинуса, которая позволяет рассчитать расстояние между двумя точками на земле
 	//Логика: если точка(координаты пользователя) находятся в радиусе инцидента - инцидент попадает в слайс инцидентов
 	//в которых сейчас находится пользователь и для инцидента в статистику записывается конкретный юзер (требования условия)
+	//Фильтры необязательные: пустой массив категорий ($6) и нулевой уровень опасности ($7) ничего не отсекают,
+	//а без статусов ($8) отдаём только действующие инциденты; для active дополнительно проверяем окно действия
 	query := ` 
-    SELECT id, title, description, lat, lon, radius_meters, is_active, created_at 
-    FROM incidents 
-    WHERE (
-        6371000 * acos(
+    SELECT ` + incidentColumns + ` 
+    FROM (
+        SELECT *, 6371000 * acos(
             cos(radians($1)) * cos(radians(lat)) * cos(radians(lon) - radians($2)) + 
             sin(radians($1)) * sin(radians(lat))
-        )
-    ) <= (radius_meters + $5) 
-    AND is_active = true
+        ) AS distance
+        FROM incidents
+        WHERE tenant_id = $9
+        AND status = ANY($8::text[])
+        AND (status <> 'active' OR (
+            (valid_from IS NULL OR valid_from <= NOW())
+            AND (valid_until IS NULL OR valid_until > NOW())
+        ))
+    ) AS i
+    WHERE distance <= (radius_meters + $5) 
+    AND (cardinality($6::text[]) = 0 OR category = ANY($6::text[]))
+    AND severity >= $7
+    ORDER BY severity DESC, distance ASC
     LIMIT $3 OFFSET $4`
-	rows, err := r.conn.Query(ctx, query, lat, long, limit, offset, extraRadius)
+	categories := make([]string, len(filter.Categories))
+	for idx, c := range filter.Categories {
+		categories[idx] = string(c)
+	}
+	statuses := []string{string(domain.StatusActive)}
+	if len(filter.Statuses) > 0 {
+		statuses = make([]string, len(filter.Statuses))
+		for idx, st := range filter.Statuses {
+			statuses[idx] = string(st)
+		}
+	}
+	rows, err := r.conn.Query(ctx, query, lat, long, limit, offset, extraRadius, categories, filter.MinSeverity, statuses, domain.TenantIDFromContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("ошибка выполнения запроса к базе данных: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var i domain.Incident
-		err = rows.Scan(&i.ID, &i.Title, &i.Description, &i.Latitude, &i.Longitude, &i.RadiusMeters, &i.IsActive, &i.CreatedAt)
+		i, err := scanIncident(rows)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка чтения данных из результата запроса: %w", err)
 		}
-		incidents = append(incidents, &i)
+		incidents = append(incidents, i)
 	}
 
 	// Проверяем, не было ли ошибок во время итерации по строкам
@@ -169,30 +282,58 @@ func (r *PostgresStorage) SaveCheck(ctx context.Context, userID string, lat, lon
 	if r.conn == nil {
 		return fmt.Errorf("подключение к базе данных не инициализировано")
 	}
-	query := ` INSERT INTO location_checks (user_id, lat, lon, incident_ids) 
-        VALUES ($1, $2, $3, $4)`
+	query := ` INSERT INTO location_checks (user_id, lat, lon, incident_ids, tenant_id) 
+        VALUES ($1, $2, $3, $4, $5)`
 
-	_, err := r.conn.Exec(ctx, query, userID, lat, lon, incidentIDs)
+	_, err := r.conn.Exec(ctx, query, userID, lat, lon, incidentIDs, domain.TenantIDFromContext(ctx))
 	if err != nil {
 		return fmt.Errorf("ошибка при сохранении лога в БД: %w", err)
 	}
 	return nil
 }
 
-// GetStats отвечает за то, чтобы отдавать user_count(уникальные user_id за N минут по условию) для запрашиваемого инцидента
-func (r *PostgresStorage) GetStats(ctx context.Context, minutes int) ([]domain.StatisticResponse, error) {
+// rolledUntil - CTE с границей, до которой проверки организации ($N) уже свёрнуты в таблицу почасовых итогов table.
+// Раньше неё считаем по почасовым итогам (сырые строки там могли быть уже удалены), начиная с неё - по location_checks.
+// Без свёрнутых часов граница -infinity и всё считается по сырым строкам
+func rolledUntil(table, tenantParam string) string {
+	return `rolled AS (
+            SELECT COALESCE(MAX(hour) + INTERVAL '1 hour', '-infinity'::timestamptz) AS until
+            FROM ` + table + ` WHERE tenant_id = ` + tenantParam + `
+        )`
+}
+
+// GetStats отвечает за то, чтобы отдавать user_count(уникальные user_id за период [From, To)) для инцидентов.
+// За свёрнутые часы, начавшиеся внутри периода, складываются почасовые уникальные пользователи: пользователь,
+// проверявший координаты в разные часы, считается несколько раз, поэтому такой итог помечается как приблизительный
+func (r *PostgresStorage) GetStats(ctx context.Context, q domain.StatsQuery) ([]domain.StatisticResponse, error) {
 	if r.conn == nil {
 		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
 	}
-	//запрашиваем список инцидентов и кол-во уникальных юзеров за указанный период времени в минутах,
-	//разворачивая массив incident_ids
+	//запрашиваем список инцидентов и кол-во уникальных юзеров за указанный период времени,
+	//разворачивая массив incident_ids; архивные инциденты в статистику не попадают, завершённые (resolved) - попадают
 	query := `
-        SELECT unnest(incident_ids) AS incident_id, COUNT(DISTINCT user_id)
-        FROM location_checks
-        WHERE checked_at >= NOW() - (interval '1 minute' * $1)
-        GROUP BY incident_id`
+        WITH ` + rolledUntil("location_stats_hourly", "$4") + `
+        SELECT c.incident_id, SUM(c.users)::bigint, bool_or(c.rolled)
+        FROM (
+            SELECT incident_id, COUNT(DISTINCT user_id) AS users, false AS rolled
+            FROM (
+                SELECT unnest(incident_ids) AS incident_id, user_id
+                FROM location_checks, rolled
+                WHERE tenant_id = $4 AND checked_at >= GREATEST($1, rolled.until) AND checked_at < $2
+            ) AS raw
+            GROUP BY incident_id
+            UNION ALL
+            SELECT h.incident_id, SUM(h.unique_users), true
+            FROM location_stats_hourly h, rolled
+            WHERE h.tenant_id = $4 AND h.hour >= $1 AND h.hour < $2 AND h.hour < rolled.until
+            GROUP BY h.incident_id
+        ) AS c
+        JOIN incidents i ON i.id = c.incident_id AND i.tenant_id = $4
+        WHERE i.status <> 'archived'
+        AND ($3::uuid IS NULL OR c.incident_id = $3)
+        GROUP BY c.incident_id`
 
-	rows, err := r.conn.Query(ctx, query, minutes)
+	rows, err := r.conn.Query(ctx, query, q.From, q.To, q.IncidentID, domain.TenantIDFromContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения статистики для инцидента: %w", err)
 	}
@@ -203,10 +344,197 @@ func (r *PostgresStorage) GetStats(ctx context.Context, minutes int) ([]domain.S
 		//В каждой итерации создаем локальную переменную в которую записываем результат поиска
 		//и либо возвращаем ошибку, либо записываем полученный результат в слайс stats
 		var s domain.StatisticResponse
-		if err := rows.Scan(&s.IncidentID, &s.UserCount); err != nil {
+		if err := rows.Scan(&s.IncidentID, &s.UserCount, &s.Approximate); err != nil {
 			return nil, err
 		}
 		stats = append(stats, s)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов запроса: %w", err)
+	}
 	return stats, nil
 }
+
+// GetStatsSeries отдаёт количество уникальных пользователей по интервалам q.Bucket для каждого инцидента.
+// Интервалы без проверок в результат не попадают, их заполняет нулями сервис. Свёрнутый час целиком
+// относится к интервалу, в котором он начинается, и, как в GetStats, уникальные пользователи часов складываются
+func (r *PostgresStorage) GetStatsSeries(ctx context.Context, q domain.StatsQuery) (map[string][]domain.StatsPoint, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
+	}
+	//интервалы считаем в UTC, чтобы границы дней не зависели от часового пояса сессии
+	query := `
+        WITH ` + rolledUntil("location_stats_hourly", "$5") + `
+        SELECT c.incident_id, c.bucket, SUM(c.users)::bigint
+        FROM (
+            SELECT incident_id, date_trunc($3, checked_at, 'UTC') AS bucket, COUNT(DISTINCT user_id) AS users
+            FROM (
+                SELECT unnest(incident_ids) AS incident_id, user_id, checked_at
+                FROM location_checks, rolled
+                WHERE tenant_id = $5 AND checked_at >= GREATEST($1, rolled.until) AND checked_at < $2
+            ) AS raw
+            GROUP BY incident_id, bucket
+            UNION ALL
+            SELECT h.incident_id, date_trunc($3, h.hour, 'UTC') AS bucket, SUM(h.unique_users)
+            FROM location_stats_hourly h, rolled
+            WHERE h.tenant_id = $5 AND h.hour >= $1 AND h.hour < $2 AND h.hour < rolled.until
+            GROUP BY h.incident_id, bucket
+        ) AS c
+        JOIN incidents i ON i.id = c.incident_id AND i.tenant_id = $5
+        WHERE i.status <> 'archived'
+        AND ($4::uuid IS NULL OR c.incident_id = $4)
+        GROUP BY c.incident_id, c.bucket
+        ORDER BY c.incident_id, c.bucket`
+
+	rows, err := r.conn.Query(ctx, query, q.From, q.To, string(q.Bucket), q.IncidentID, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статистики по интервалам: %w", err)
+	}
+	defer rows.Close()
+
+	series := make(map[string][]domain.StatsPoint)
+	for rows.Next() {
+		var incidentID string
+		var p domain.StatsPoint
+		if err := rows.Scan(&incidentID, &p.BucketStart, &p.UserCount); err != nil {
+			return nil, err
+		}
+		p.BucketStart = p.BucketStart.UTC()
+		series[incidentID] = append(series[incidentID], p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов запроса: %w", err)
+	}
+	return series, nil
+}
+
+// GetHeatmap группирует проверки за период [from, to) по ячейкам сетки размером cellLat x cellLon градусов
+// и отдаёт для каждой непустой ячейки её центр и счётчики; geohash ячейки считает сервис.
+// Свёрнутые часы берутся из итогов по самой мелкой сетке: её ячейки вложены в ячейки любой более крупной,
+// поэтому центр мелкой ячейки однозначно попадает в одну из них. Уникальные пользователи мелких ячеек
+// и разных часов при этом складываются, и такая ячейка помечается как приблизительная
+func (r *PostgresStorage) GetHeatmap(ctx context.Context, from, to time.Time, cellLat, cellLon float64) ([]domain.HeatmapCell, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
+	}
+	//номер ячейки по широте и долготе считается от южного полюса и антимеридиана, как в geohash
+	query := `
+        WITH ` + rolledUntil("location_heatmap_hourly", "$5") + `
+        SELECT y, x, SUM(checks)::bigint, SUM(users)::bigint, SUM(in_danger)::bigint, bool_or(rolled)
+        FROM (
+            SELECT floor((lat + 90) / $3) AS y, floor((lon + 180) / $4) AS x,
+                COUNT(*) AS checks, COUNT(DISTINCT user_id) AS users, COUNT(*) FILTER (WHERE cardinality(incident_ids) > 0) AS in_danger,
+                false AS rolled
+            FROM location_checks, rolled
+            WHERE tenant_id = $5 AND checked_at >= GREATEST($1, rolled.until) AND checked_at < $2
+            GROUP BY y, x
+            UNION ALL
+            SELECT floor((h.lat + 90) / $3) AS y, floor((h.lon + 180) / $4) AS x,
+                SUM(h.checks), SUM(h.unique_users), SUM(h.in_danger), true
+            FROM location_heatmap_hourly h, rolled
+            WHERE h.tenant_id = $5 AND h.hour >= $1 AND h.hour < $2 AND h.hour < rolled.until
+            GROUP BY y, x
+        ) AS c
+        GROUP BY y, x`
+
+	rows, err := r.conn.Query(ctx, query, from, to, cellLat, cellLon, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения тепловой карты: %w", err)
+	}
+	defer rows.Close()
+
+	cells := make([]domain.HeatmapCell, 0)
+	for rows.Next() {
+		var y, x float64
+		var cell domain.HeatmapCell
+		if err := rows.Scan(&y, &x, &cell.Checks, &cell.UniqueUsers, &cell.InDangerChecks, &cell.Approximate); err != nil {
+			return nil, err
+		}
+		cell.Latitude = -90 + (y+0.5)*cellLat
+		cell.Longitude = -180 + (x+0.5)*cellLon
+		cells = append(cells, cell)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов запроса: %w", err)
+	}
+	return cells, nil
+}
+
+// ActivateScheduled переводит в active запланированные инциденты, у которых наступило время valid_from, и возвращает их
+func (r *PostgresStorage) ActivateScheduled(ctx context.Context, now time.Time) ([]*domain.Incident, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
+	}
+	where := `status = 'scheduled'
+        AND valid_from IS NOT NULL AND valid_from <= $1
+        AND (valid_until IS NULL OR valid_until > $1)`
+	return r.switchScheduled(ctx, where, domain.StatusActive, now)
+}
+
+// ExpireScheduled переводит в resolved инциденты, у которых прошло время valid_until, и возвращает их
+func (r *PostgresStorage) ExpireScheduled(ctx context.Context, now time.Time) ([]*domain.Incident, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("подключение к базе данных не инициализировано")
+	}
+	where := `status IN ('scheduled', 'active')
+        AND valid_until IS NOT NULL AND valid_until <= $1`
+	return r.switchScheduled(ctx, where, domain.StatusResolved, now)
+}
+
+// switchScheduled блокирует подходящие под условие планировщика инциденты, меняет им статус
+// и записывает версию для каждого из них. Планировщик общий для всех организаций, поэтому
+// здесь фильтра по организации нет: каждый инцидент несёт свой TenantID дальше в вебхуки и кэш
+func (r *PostgresStorage) switchScheduled(ctx context.Context, where string, to domain.IncidentStatus, now time.Time) ([]*domain.Incident, error) {
+	var incidents []*domain.Incident
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		olds, err := queryIncidents(ctx, tx, `SELECT `+incidentColumns+` FROM incidents WHERE `+where+` FOR UPDATE`, now)
+		if err != nil || len(olds) == 0 {
+			return err
+		}
+		ids := make([]uuid.UUID, len(olds))
+		for idx, old := range olds {
+			ids[idx] = old.ID
+		}
+		incidents, err = queryIncidents(ctx, tx, `UPDATE incidents SET status = $1 WHERE id = ANY($2) RETURNING `+incidentColumns, to, ids)
+		if err != nil {
+			return err
+		}
+		//порядок строк в RETURNING не гарантирован, поэтому сопоставляем версии по id
+		byID := make(map[uuid.UUID]*domain.Incident, len(olds))
+		for _, old := range olds {
+			byID[old.ID] = old
+		}
+		for _, inc := range incidents {
+			if err := saveVersion(ctx, tx, domain.ActionStatus, byID[inc.ID], inc, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return incidents, nil
+}
+
+// queryIncidents выполняет запрос, возвращающий строки инцидентов, и собирает их в слайс
+func queryIncidents(ctx context.Context, q querier, query string, args ...any) ([]*domain.Incident, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса к базе данных: %w", err)
+	}
+	defer rows.Close()
+
+	incidents := make([]*domain.Incident, 0)
+	for rows.Next() {
+		i, err := scanIncident(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения данных из результата запроса: %w", err)
+		}
+		incidents = append(incidents, i)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов запроса: %w", err)
+	}
+	return incidents, nil
+}
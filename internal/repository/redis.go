@@ -2,24 +2,66 @@ package repository
 
 import (
 	"RedCollar/internal/domain"
+	"RedCollar/internal/i18n"
+	"RedCollar/internal/tracing"
 	"context"
 	"encoding/json"
 	"errors"
+	"math/rand/v2"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrQueueEmpty - за время ожидания в очереди вебхуков ничего не появилось
+var ErrQueueEmpty = errors.New("очередь вебхуков пуста")
+
+// popTimeout - сколько PopWebhook ждёт вебхук, после чего воркер отмечается в heartbeat и снова ждёт
+const popTimeout = 5 * time.Second
+
 // ErrCacheMiss будет использоваться в кейсах redis.Nil, чтобы не тянуть зависимости в другие слои программы
 var ErrCacheMiss = errors.New("Кэш отстутствует")
 
 type RedisRepository interface {
 	SetCache(ctx context.Context, key string, value interface{}, ttl time.Duration) error
 	GetCache(ctx context.Context, key string) ([]byte, error)
+	DeleteCacheByPrefix(ctx context.Context, prefix string) error
+	TrackUniqueUsers(ctx context.Context, userID string, incidentIDs []uuid.UUID, at time.Time, ttl time.Duration) error
+	StatsIncidents(ctx context.Context, from time.Time) ([]string, error)
+	ForgetStatsIncident(ctx context.Context, incidentID string) error
+	CountUniqueUsers(ctx context.Context, ranges []domain.UniqueUsersRange) ([]int, error)
+	TrackPosition(ctx context.Context, userID string, lat, lon float64, at time.Time) error
+	UsersInRadius(ctx context.Context, lat, lon, radius float64, since time.Time) ([]domain.LiveUser, error)
+	PrunePositions(ctx context.Context, before time.Time) error
+	ForgetPosition(ctx context.Context, userID string) (bool, error)
+	TakeToken(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error)
+	Ping(ctx context.Context) error
 	Close() error
 	WebhookPush(ctx context.Context, webhook domain.Webhook) error
 	PopWebhook(ctx context.Context) (domain.Webhook, error)
+	RequeueWebhook(ctx context.Context, webhook domain.Webhook) error
+	QueueLength(ctx context.Context) (int64, error)
+	DropUserWebhooks(ctx context.Context, userID string) (int64, error)
 }
+
+const (
+	//webhookQueuePrefix - у каждой организации своя очередь webhook_q:<tenant_id>,
+	//чтобы очередь одной организации можно было увидеть и разобрать отдельно от остальных
+	webhookQueuePrefix = "webhook_q:"
+	//webhookQueuesKey - множество очередей, в которые когда-либо клали вебхуки, воркер слушает их все
+	webhookQueuesKey = "webhook_queues"
+	//legacyWebhookQueue - общая очередь до появления организаций, её вебхуки относятся к организации по умолчанию
+	legacyWebhookQueue = "webhook_q"
+)
+
+// tenantKey добавляет к ключу ID организации из контекста, чтобы данные организаций в redis не пересекались
+func tenantKey(ctx context.Context, key string) string {
+	return key + ":" + domain.TenantIDFromContext(ctx).String()
+}
+
 type redisRepository struct {
 	rdb *redis.Client
 }
@@ -28,12 +70,21 @@ func RedisConnection(ctx context.Context, redisAddr string) (RedisRepository, er
 	rdb := redis.NewClient(&redis.Options{
 		Addr: redisAddr,
 	})
+	//каждая команда (и пайплайн целиком) попадает в трейс отдельным спаном
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		return nil, err
+	}
 	if err := rdb.Ping(ctx).Err(); err != nil {
 		return nil, err
 	}
 	return &redisRepository{rdb: rdb}, nil
 }
 
+// Ping проверяет, что redis доступен
+func (r *redisRepository) Ping(ctx context.Context) error {
+	return r.rdb.Ping(ctx).Err()
+}
+
 func (r *redisRepository) Close() error {
 	err := r.rdb.Close()
 	if err != nil {
@@ -42,28 +93,143 @@ func (r *redisRepository) Close() error {
 	return nil
 }
 
-// Метод отвечает за добавление объекта в очередь
+// Метод отвечает за добавление объекта в очередь организации webhook.TenantID (если не указана - организации из контекста)
 func (r *redisRepository) WebhookPush(ctx context.Context, webhook domain.Webhook) error {
+	if webhook.TenantID == uuid.Nil {
+		webhook.TenantID = domain.TenantIDFromContext(ctx)
+	}
+	//сохраняем trace context, чтобы спан отправки в воркере ссылался на проверку, из которой появился вебхук
+	if webhook.TraceContext == nil {
+		webhook.TraceContext = tracing.Inject(ctx)
+	}
+	if webhook.RequestID == "" {
+		webhook.RequestID = domain.RequestIDFromContext(ctx)
+	}
+	//тексты вебхука пишутся на языке запроса, из-за которого он появился
+	if webhook.Lang == "" {
+		webhook.Lang = string(i18n.FromContext(ctx))
+	}
+
 	// Сериализируем данные в json
 	data, err := json.Marshal(webhook)
 	if err != nil {
 		return err
 	}
 
-	//вызываем метод, LPush и сохраняем данные в список webhook_q + распаковываем ошибку
-	return r.rdb.LPush(ctx, "webhook_q", data).Err()
+	//кладём вебхук в очередь организации и регистрируем очередь, чтобы воркер начал её слушать
+	queue := webhookQueuePrefix + webhook.TenantID.String()
+	_, err = r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, webhookQueuesKey, queue)
+		pipe.LPush(ctx, queue, data)
+		return nil
+	})
+	return err
+}
+
+// webhookQueues отдаёт все очереди вебхуков вместе с общей очередью, оставшейся от прошлых версий
+func (r *redisRepository) webhookQueues(ctx context.Context) ([]string, error) {
+	queues, err := r.rdb.SMembers(ctx, webhookQueuesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	return append(queues, legacyWebhookQueue), nil
 }
 
 // Метод получения данных из очереди
 func (r *redisRepository) PopWebhook(ctx context.Context) (domain.Webhook, error) {
-	//BRPop позволяет в случае отсутствия данных в списке просто ожидать(0 секунд timeout = бесконечно) пока что-нибудь появится
-	result, err := r.rdb.BRPop(ctx, 0, "webhook_q").Result()
+	queues, err := r.webhookQueues(ctx)
 	if err != nil {
 		return domain.Webhook{}, err
 	}
+	//BRPop забирает из первой непустой очереди в порядке перечисления, поэтому перемешиваем их,
+	//чтобы большая очередь одной организации не задерживала вебхуки остальных
+	rand.Shuffle(len(queues), func(i, j int) { queues[i], queues[j] = queues[j], queues[i] })
+
+	//BRPop позволяет в случае отсутствия данных в списке просто ожидать пока что-нибудь появится
+	//ждём не бесконечно, чтобы воркер регулярно отмечался в heartbeat даже при пустой очереди
+	result, err := r.rdb.BRPop(ctx, popTimeout, queues...).Result()
+	if err == redis.Nil {
+		return domain.Webhook{}, ErrQueueEmpty
+	} else if err != nil {
+		return domain.Webhook{}, err
+	}
 	var webhook domain.Webhook
 	err = json.Unmarshal([]byte(result[1]), &webhook) //анмаршалим json данные в переменную
-	return webhook, nil                               // и возвращаем результат
+	//организацию определяем по очереди, из которой забрали вебхук
+	webhook.TenantID = domain.DefaultTenantID
+	if id, err := uuid.Parse(strings.TrimPrefix(result[0], webhookQueuePrefix)); err == nil {
+		webhook.TenantID = id
+	}
+	return webhook, nil // и возвращаем результат
+}
+
+// RequeueWebhook возвращает взятый из очереди вебхук обратно, туда, откуда его заберут первым.
+// Нужен, когда воркер останавливается, не успев доставить вебхук
+func (r *redisRepository) RequeueWebhook(ctx context.Context, webhook domain.Webhook) error {
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+	queue := webhookQueuePrefix + webhook.TenantID.String()
+	_, err = r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, webhookQueuesKey, queue)
+		pipe.RPush(ctx, queue, data) //PopWebhook читает справа
+		return nil
+	})
+	return err
+}
+
+// DropUserWebhooks убирает из очереди организации ещё не отправленные вебхуки о пользователе и отдаёт их количество
+func (r *redisRepository) DropUserWebhooks(ctx context.Context, userID string) (int64, error) {
+	tenantID := domain.TenantIDFromContext(ctx)
+	queues := []string{webhookQueuePrefix + tenantID.String()}
+	if tenantID == domain.DefaultTenantID {
+		queues = append(queues, legacyWebhookQueue)
+	}
+
+	var dropped int64
+	for _, queue := range queues {
+		items, err := r.rdb.LRange(ctx, queue, 0, -1).Result()
+		if err != nil {
+			return dropped, err
+		}
+		for _, item := range items {
+			var webhook domain.Webhook
+			if err := json.Unmarshal([]byte(item), &webhook); err != nil || webhook.UserID != userID {
+				continue
+			}
+			//вебхук мог уже уйти воркеру, тогда LRem ничего не удалит
+			n, err := r.rdb.LRem(ctx, queue, 1, item).Result()
+			if err != nil {
+				return dropped, err
+			}
+			dropped += n
+		}
+	}
+	return dropped, nil
+}
+
+// QueueLength отдаёт количество вебхуков, ожидающих отправки во всех очередях
+func (r *redisRepository) QueueLength(ctx context.Context) (int64, error) {
+	queues, err := r.webhookQueues(ctx)
+	if err != nil {
+		return 0, err
+	}
+	lens := make([]*redis.IntCmd, len(queues))
+	_, err = r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for idx, queue := range queues {
+			lens[idx] = pipe.LLen(ctx, queue)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, l := range lens {
+		total += l.Val()
+	}
+	return total, nil
 }
 
 // SetCache - универсальный метод для кэширования пары ключ-значение и в нашем случае мы будем его настраивать на работу
@@ -87,3 +253,26 @@ func (r *redisRepository) GetCache(ctx context.Context, key string) ([]byte, err
 	}
 	return res, nil
 }
+
+// DeleteCacheByPrefix удаляет все ключи кэша с указанным префиксом, SCAN используется вместо KEYS, чтобы не блокировать redis
+func (r *redisRepository) DeleteCacheByPrefix(ctx context.Context, prefix string) error {
+	iter := r.rdb.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	keys := make([]string, 0, 100)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		//удаляем пачками, чтобы не собирать в памяти все ключи сразу
+		if len(keys) == cap(keys) {
+			if err := r.rdb.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return r.rdb.Del(ctx, keys...).Err()
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Ключи ниже заводятся на каждую организацию (live:positions:<tenant_id>), чтобы в радиус инцидента
+// не попадали пользователи другой организации
+const (
+	//livePositionsKey - GEO set с последней позицией каждого пользователя
+	livePositionsKey = "live:positions"
+	//liveSeenKey - sorted set с временем последней проверки каждого пользователя
+	liveSeenKey = "live:seen"
+)
+
+// TrackPosition запоминает последнюю позицию пользователя и время проверки, старая позиция перезаписывается
+func (r *redisRepository) TrackPosition(ctx context.Context, userID string, lat, lon float64, at time.Time) error {
+	positionsKey, seenKey := tenantKey(ctx, livePositionsKey), tenantKey(ctx, liveSeenKey)
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.GeoAdd(ctx, positionsKey, &redis.GeoLocation{Name: userID, Latitude: lat, Longitude: lon})
+		pipe.ZAdd(ctx, seenKey, redis.Z{Score: float64(at.Unix()), Member: userID})
+		return nil
+	})
+	return err
+}
+
+// UsersInRadius отдаёт пользователей, последняя позиция которых находится в радиусе radius метров от точки
+// и которые проверяли координаты не раньше since
+func (r *redisRepository) UsersInRadius(ctx context.Context, lat, lon, radius float64, since time.Time) ([]domain.LiveUser, error) {
+	locations, err := r.rdb.GeoSearchLocation(ctx, tenantKey(ctx, livePositionsKey), &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Latitude:   lat,
+			Longitude:  lon,
+			Radius:     radius,
+			RadiusUnit: "m",
+		},
+		WithCoord: true,
+	}).Result()
+	if err != nil || len(locations) == 0 {
+		return []domain.LiveUser{}, err
+	}
+
+	names := make([]string, len(locations))
+	for idx, loc := range locations {
+		names[idx] = loc.Name
+	}
+	seen, err := r.rdb.ZMScore(ctx, tenantKey(ctx, liveSeenKey), names...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]domain.LiveUser, 0, len(locations))
+	for idx, loc := range locations {
+		//отбрасываем пользователей, которые давно не проверяли координаты - их позиция уже неактуальна
+		seenAt := time.Unix(int64(seen[idx]), 0)
+		if seenAt.Before(since) {
+			continue
+		}
+		users = append(users, domain.LiveUser{
+			UserID:    loc.Name,
+			Latitude:  loc.Latitude,
+			Longitude: loc.Longitude,
+			SeenAt:    seenAt,
+		})
+	}
+	return users, nil
+}
+
+// PrunePositions удаляет позиции пользователей организации, которые не проверяли координаты с момента before
+func (r *redisRepository) PrunePositions(ctx context.Context, before time.Time) error {
+	positionsKey, seenKey := tenantKey(ctx, livePositionsKey), tenantKey(ctx, liveSeenKey)
+	stale, err := r.rdb.ZRangeByScore(ctx, seenKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(before.Unix(), 10),
+	}).Result()
+	if err != nil || len(stale) == 0 {
+		return err
+	}
+	members := make([]any, len(stale))
+	for idx, userID := range stale {
+		members[idx] = userID
+	}
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, positionsKey, members...)
+		pipe.ZRem(ctx, seenKey, members...)
+		return nil
+	})
+	return err
+}
+
+// ForgetPosition удаляет последнюю позицию пользователя в организации, found - позиция была сохранена
+func (r *redisRepository) ForgetPosition(ctx context.Context, userID string) (bool, error) {
+	var removed *redis.IntCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx, tenantKey(ctx, livePositionsKey), userID)
+		pipe.ZRem(ctx, tenantKey(ctx, liveSeenKey), userID)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
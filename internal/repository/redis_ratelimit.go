@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitPrefix - префикс ключей корзин rate limiter, корзина заводится отдельно в каждой организации
+const rateLimitPrefix = "rl:"
+
+// tokenBucket атомарно пополняет корзину по прошедшему времени и пытается взять из неё один токен.
+// Состояние корзины - hash {tokens, ts}, ключ живёт пока корзина не наполнится снова
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], reset + 1000)
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// TakeToken берёт токен из корзины key организации из контекста по лимиту limit
+func (r *redisRepository) TakeToken(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	//скорость пополнения в токенах за миллисекунду
+	rate := float64(limit.Requests) / float64(limit.Period.Milliseconds())
+	res, err := tokenBucket.Run(ctx, r.rdb, []string{tenantKey(ctx, rateLimitPrefix+key)},
+		limit.Capacity(), rate, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return domain.RateLimitResult{}, fmt.Errorf("ошибка rate limiter: %w", err)
+	}
+	return domain.RateLimitResult{
+		Allowed:    res[0] == 1,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		Reset:      time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	//statsIncidentsKey - sorted set инцидентов организации, по которым есть счётчики, score - время последней проверки
+	statsIncidentsKey = "stats:incidents"
+	//statsHLLPrefix - префикс поминутных HyperLogLog счётчиков: stats:hll:<tenant_id>:<incident_id>:<unix минута>
+	statsHLLPrefix = "stats:hll:"
+	//maxMergedStatsKeys - сколько поминутных счётчиков может объединить один вызов CountUniqueUsers:
+	//длинный период с разбивкой по дням затрагивает тысячи ключей на инцидент, такой запрос дешевле посчитать в postgres
+	maxMergedStatsKeys = 10000
+)
+
+// ErrTooManyStatsKeys - период слишком длинный, чтобы считать его по поминутным счётчикам
+var ErrTooManyStatsKeys = errors.New("слишком много счётчиков статистики для подсчёта в redis")
+
+// statsHLLKey включает организацию, чтобы по ID чужого инцидента нельзя было прочитать его счётчики
+func statsHLLKey(ctx context.Context, incidentID string, minute time.Time) string {
+	return statsHLLPrefix + domain.TenantIDFromContext(ctx).String() + ":" + incidentID + ":" + strconv.FormatInt(minute.Unix()/60, 10)
+}
+
+// TrackUniqueUsers добавляет пользователя в поминутные счётчики каждого инцидента, ttl - сколько хранить счётчик
+func (r *redisRepository) TrackUniqueUsers(ctx context.Context, userID string, incidentIDs []uuid.UUID, at time.Time, ttl time.Duration) error {
+	if len(incidentIDs) == 0 {
+		return nil
+	}
+	minute := at.Truncate(time.Minute)
+	indexKey := tenantKey(ctx, statsIncidentsKey)
+
+	//все команды отправляем одним пайплайном, чтобы не делать по несколько запросов на каждый инцидент
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range incidentIDs {
+			key := statsHLLKey(ctx, id.String(), minute)
+			pipe.PFAdd(ctx, key, userID)
+			pipe.Expire(ctx, key, ttl)
+			pipe.ZAdd(ctx, indexKey, redis.Z{Score: float64(at.Unix()), Member: id.String()})
+		}
+		//инциденты без проверок дольше ttl из индекса убираем, их счётчики уже истекли
+		pipe.ZRemRangeByScore(ctx, indexKey, "-inf", strconv.FormatInt(at.Add(-ttl).Unix(), 10))
+		return nil
+	})
+	return err
+}
+
+// StatsIncidents отдаёт id инцидентов, по которым были проверки начиная с момента from
+func (r *redisRepository) StatsIncidents(ctx context.Context, from time.Time) ([]string, error) {
+	return r.rdb.ZRangeByScore(ctx, tenantKey(ctx, statsIncidentsKey), &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.Unix(), 10),
+		Max: "+inf",
+	}).Result()
+}
+
+// ForgetStatsIncident убирает инцидент из индекса счётчиков, например когда он уходит в архив
+func (r *redisRepository) ForgetStatsIncident(ctx context.Context, incidentID string) error {
+	return r.rdb.ZRem(ctx, tenantKey(ctx, statsIncidentsKey), incidentID).Err()
+}
+
+// CountUniqueUsers считает приблизительное количество уникальных пользователей для каждого периода [From, To):
+// поминутные счётчики периода объединяются через PFMERGE во временный ключ, который сразу удаляется.
+// Все периоды считаются одним пайплайном, результат идёт в том же порядке, что и ranges.
+// Если всего нужно объединить больше maxMergedStatsKeys счётчиков, в redis ничего не отправляется и отдаётся ErrTooManyStatsKeys
+func (r *redisRepository) CountUniqueUsers(ctx context.Context, ranges []domain.UniqueUsersRange) ([]int, error) {
+	//столько же минут, сколько переберёт цикл ниже
+	keys := 0
+	for _, rng := range ranges {
+		if span := rng.To.Sub(rng.From.Truncate(time.Minute)); span > 0 {
+			keys += int((span + time.Minute - 1) / time.Minute)
+		}
+	}
+	if keys > maxMergedStatsKeys {
+		return nil, ErrTooManyStatsKeys
+	}
+
+	cmds := make([]*redis.IntCmd, len(ranges))
+	tmp := "stats:tmp:" + uuid.NewString() + ":"
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for idx, rng := range ranges {
+			keys := make([]string, 0)
+			for minute := rng.From.Truncate(time.Minute); minute.Before(rng.To); minute = minute.Add(time.Minute) {
+				keys = append(keys, statsHLLKey(ctx, rng.IncidentID, minute))
+			}
+			//пустой период - ноль без запроса в redis
+			if len(keys) > 0 {
+				key := tmp + strconv.Itoa(idx)
+				pipe.PFMerge(ctx, key, keys...)
+				cmds[idx] = pipe.PFCount(ctx, key)
+				pipe.Del(ctx, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подсчёта уникальных пользователей: %w", err)
+	}
+	counts := make([]int, len(ranges))
+	for idx, cmd := range cmds {
+		if cmd != nil {
+			counts[idx] = int(cmd.Val())
+		}
+	}
+	return counts, nil
+}
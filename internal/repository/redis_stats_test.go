@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCountUniqueUsersLimitsMergedKeys(t *testing.T) {
+	m, rdb := newTestRedis(t)
+	ctx := tenantCtx()
+	from := time.Now().Truncate(time.Minute)
+	id := uuid.NewString()
+
+	//ровно maxMergedStatsKeys минут ещё считаются
+	limit := []domain.UniqueUsersRange{{IncidentID: id, From: from, To: from.Add(maxMergedStatsKeys * time.Minute)}}
+	if _, err := rdb.CountUniqueUsers(ctx, limit); err != nil {
+		t.Fatalf("период на границе лимита: %v", err)
+	}
+
+	//лимит общий на все периоды вызова, например по одному интервалу на каждый инцидент
+	over := []domain.UniqueUsersRange{
+		{IncidentID: id, From: from, To: from.Add(maxMergedStatsKeys / 2 * time.Minute)},
+		{IncidentID: uuid.NewString(), From: from, To: from.Add((maxMergedStatsKeys/2 + 1) * time.Minute)},
+	}
+	before := m.CommandCount()
+	if _, err := rdb.CountUniqueUsers(ctx, over); !errors.Is(err, ErrTooManyStatsKeys) {
+		t.Fatalf("ожидали ErrTooManyStatsKeys, получили %v", err)
+	}
+	if m.CommandCount() != before {
+		t.Fatal("при превышении лимита команды всё равно ушли в redis")
+	}
+}
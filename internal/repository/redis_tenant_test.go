@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, RedisRepository) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	rdb, err := RedisConnection(context.Background(), m.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	return m, rdb
+}
+
+func tenantCtx() context.Context {
+	return domain.WithTenant(context.Background(), &domain.Tenant{ID: uuid.New()})
+}
+
+func TestWebhookQueueTenantIsolation(t *testing.T) {
+	m, rdb := newTestRedis(t)
+	ctxA, ctxB := tenantCtx(), tenantCtx()
+
+	if err := rdb.WebhookPush(ctxB, domain.Webhook{Event: domain.EventUserInZone, UserID: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	//организация A не может убрать из очереди вебхуки B, даже про пользователя с тем же user_id
+	dropped, err := rdb.DropUserWebhooks(ctxA, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 0 {
+		t.Fatalf("из очереди организации A удалены чужие вебхуки: %d", dropped)
+	}
+	if m.Exists(webhookQueuePrefix + domain.TenantIDFromContext(ctxA).String()) {
+		t.Fatal("вебхук организации B попал в очередь A")
+	}
+
+	webhook, err := rdb.PopWebhook(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webhook.TenantID != domain.TenantIDFromContext(ctxB) {
+		t.Fatalf("вебхук доставляется от имени организации %s, ожидали B", webhook.TenantID)
+	}
+}
+
+func TestStatsCountersTenantIsolation(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	ctxA, ctxB := tenantCtx(), tenantCtx()
+	incidentID := uuid.New()
+	now := time.Now()
+
+	if err := rdb.TrackUniqueUsers(ctxB, "user", []uuid.UUID{incidentID}, now, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := rdb.StatsIncidents(ctxA, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Fatalf("в индексе счётчиков организации A чужие инциденты: %v", ids)
+	}
+	//даже зная ID чужого инцидента, A читает только свои счётчики
+	rng := []domain.UniqueUsersRange{{IncidentID: incidentID.String(), From: now.Add(-time.Minute), To: now.Add(time.Minute)}}
+	counts, err := rdb.CountUniqueUsers(ctxA, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[0] != 0 {
+		t.Fatalf("организация A видит счётчик чужого инцидента: %d", counts[0])
+	}
+	if counts, _ := rdb.CountUniqueUsers(ctxB, rng); counts[0] != 1 {
+		t.Fatalf("организация B не видит свой счётчик: %v", counts)
+	}
+}
+
+// UsersInRadius использует GEOSEARCH, которого нет в miniredis, поэтому проверяем ключи позиций напрямую
+func TestLivePositionsTenantIsolation(t *testing.T) {
+	m, rdb := newTestRedis(t)
+	ctxA, ctxB := tenantCtx(), tenantCtx()
+
+	if err := rdb.TrackPosition(ctxB, "user", 55.75, 37.61, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if m.Exists(tenantKey(ctxA, livePositionsKey)) || m.Exists(tenantKey(ctxA, liveSeenKey)) {
+		t.Fatal("позиция пользователя организации B записана в ключи A")
+	}
+	found, err := rdb.ForgetPosition(ctxA, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Fatal("организация A удалила позицию пользователя B")
+	}
+	if err := rdb.PrunePositions(ctxA, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if members, _ := m.ZMembers(tenantKey(ctxB, livePositionsKey)); len(members) != 1 {
+		t.Fatalf("позиции организации B изменились из-за A: %v", members)
+	}
+}
+
+func TestRateLimitTenantIsolation(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	ctxA, ctxB := tenantCtx(), tenantCtx()
+	limit := domain.RateLimit{Requests: 1, Period: time.Hour}
+	key := "/api/v1/location/check:ip:203.0.113.7"
+
+	if result, err := rdb.TakeToken(ctxA, key, limit); err != nil || !result.Allowed {
+		t.Fatalf("первый запрос организации A отклонён: %+v %v", result, err)
+	}
+	if result, _ := rdb.TakeToken(ctxA, key, limit); result.Allowed {
+		t.Fatal("лимит организации A не сработал")
+	}
+	//тот же IP в организации B расходует свою корзину
+	if result, err := rdb.TakeToken(ctxB, key, limit); err != nil || !result.Allowed {
+		t.Fatalf("исчерпанный лимит организации A применился к B: %+v %v", result, err)
+	}
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RetentionRepository - перенос старых проверок координат в почасовые итоги и их удаление
+type RetentionRepository interface {
+	RollupChecks(ctx context.Context, before time.Time, cellLat, cellLon float64) (int64, error)
+	DeleteChecks(ctx context.Context, before time.Time, batch int) (int64, error)
+	DeleteDeliveries(ctx context.Context, before time.Time, batch int) (int64, error)
+	ChecksPartitioned(ctx context.Context) (bool, error)
+	DropCheckPartitions(ctx context.Context, before time.Time) ([]string, error)
+	EnsureCheckPartitions(ctx context.Context, from time.Time, days int) error
+	PartitionChecks(ctx context.Context, boundary time.Time) error
+}
+
+const (
+	//checkPartitionPrefix - дневная секция location_checks_pYYYYMMDD с проверками за [день, день+1)
+	checkPartitionPrefix = "location_checks_p"
+	//checkLegacyPrefix - секция location_checks_before_YYYYMMDD со всеми строками, что были в таблице до разбиения
+	checkLegacyPrefix = "location_checks_before_"
+	//checkPartitionDay - формат даты в имени секции, дни считаются в UTC
+	checkPartitionDay = "20060102"
+)
+
+// RollupChecks складывает итоги за каждый час, целиком закончившийся до before: по инцидентам в location_stats_hourly
+// и по ячейкам сетки cellLat x cellLon градусов в location_heatmap_hourly, в том числе проверки вне опасных зон.
+// Час, который уже был свёрнут, повторно не пересчитывается: его сырые строки могли быть частично удалены.
+// Отдаёт количество добавленных строк итогов
+func (r *PostgresStorage) RollupChecks(ctx context.Context, before time.Time, cellLat, cellLon float64) (int64, error) {
+	incidents := `
+        INSERT INTO location_stats_hourly (tenant_id, incident_id, hour, checks, unique_users)
+        SELECT tenant_id, incident_id, date_trunc('hour', checked_at) AS hour, COUNT(*), COUNT(DISTINCT user_id)
+        FROM (
+            SELECT tenant_id, unnest(incident_ids) AS incident_id, user_id, checked_at
+            FROM location_checks
+            WHERE checked_at < $1
+        ) AS c
+        GROUP BY tenant_id, incident_id, hour
+        ON CONFLICT (tenant_id, incident_id, hour) DO NOTHING`
+	//ячейка хранится своим центром, номер ячейки считается так же, как в GetHeatmap
+	cells := `
+        INSERT INTO location_heatmap_hourly (tenant_id, hour, lat, lon, checks, unique_users, in_danger)
+        SELECT tenant_id, date_trunc('hour', checked_at) AS hour,
+            -90 + (floor((lat + 90) / $2) + 0.5) * $2 AS cell_lat, -180 + (floor((lon + 180) / $3) + 0.5) * $3 AS cell_lon,
+            COUNT(*), COUNT(DISTINCT user_id), COUNT(*) FILTER (WHERE cardinality(incident_ids) > 0)
+        FROM location_checks
+        WHERE checked_at < $1
+        GROUP BY tenant_id, hour, cell_lat, cell_lon
+        ON CONFLICT (tenant_id, hour, lat, lon) DO NOTHING`
+
+	before = before.Truncate(time.Hour)
+	var rolled int64
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, incidents, before)
+		if err != nil {
+			return fmt.Errorf("ошибка сворачивания проверок в почасовые итоги по инцидентам: %w", err)
+		}
+		rolled = tag.RowsAffected()
+		if tag, err = tx.Exec(ctx, cells, before, cellLat, cellLon); err != nil {
+			return fmt.Errorf("ошибка сворачивания проверок в почасовые итоги тепловой карты: %w", err)
+		}
+		rolled += tag.RowsAffected()
+		return nil
+	})
+	return rolled, err
+}
+
+// DeleteChecks удаляет не больше batch проверок старше before, чтобы не держать долгую блокировку на большой таблице
+func (r *PostgresStorage) DeleteChecks(ctx context.Context, before time.Time, batch int) (int64, error) {
+	query := `
+        DELETE FROM location_checks
+        WHERE id IN (SELECT id FROM location_checks WHERE checked_at < $1 LIMIT $2)
+        AND checked_at < $1`
+	tag, err := r.conn.Exec(ctx, query, before, batch)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления старых проверок: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteDeliveries удаляет не больше batch записей о доставке вебхуков старше before: в них тоже есть ID пользователей
+func (r *PostgresStorage) DeleteDeliveries(ctx context.Context, before time.Time, batch int) (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE id IN (SELECT id FROM webhook_deliveries WHERE created_at < $1 LIMIT $2)`
+	tag, err := r.conn.Exec(ctx, query, before, batch)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления старых доставок вебхуков: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ChecksPartitioned - location_checks разбита на секции по checked_at командой retention partition
+func (r *PostgresStorage) ChecksPartitioned(ctx context.Context) (bool, error) {
+	query := `SELECT EXISTS (
+        SELECT 1 FROM pg_partitioned_table pt JOIN pg_class c ON c.oid = pt.partrelid
+        WHERE c.relname = 'location_checks' AND pg_table_is_visible(c.oid))`
+	var partitioned bool
+	if err := r.conn.QueryRow(ctx, query).Scan(&partitioned); err != nil {
+		return false, fmt.Errorf("ошибка проверки секционирования location_checks: %w", err)
+	}
+	return partitioned, nil
+}
+
+// checkPartitionEnd отдаёт правую границу секции по её имени, ok=false - секция не дневная и не legacy (например default)
+func checkPartitionEnd(name string) (time.Time, bool) {
+	var day string
+	var days int
+	switch {
+	case strings.HasPrefix(name, checkPartitionPrefix):
+		day, days = strings.TrimPrefix(name, checkPartitionPrefix), 1
+	case strings.HasPrefix(name, checkLegacyPrefix):
+		day = strings.TrimPrefix(name, checkLegacyPrefix)
+	default:
+		return time.Time{}, false
+	}
+	start, err := time.Parse(checkPartitionDay, day)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start.AddDate(0, 0, days), true
+}
+
+// DropCheckPartitions удаляет секции, все строки которых старше before, и отдаёт их имена.
+// Удаление секции не оставляет мёртвых строк и выполняется мгновенно в отличие от DELETE
+func (r *PostgresStorage) DropCheckPartitions(ctx context.Context, before time.Time) ([]string, error) {
+	query := `
+        SELECT c.relname FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        JOIN pg_class p ON p.oid = i.inhparent
+        WHERE p.relname = 'location_checks' AND pg_table_is_visible(p.oid)`
+	rows, err := r.conn.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения секций location_checks: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения секций location_checks: %w", err)
+	}
+
+	dropped := make([]string, 0)
+	for _, name := range names {
+		end, ok := checkPartitionEnd(name)
+		if !ok || end.After(before) {
+			continue
+		}
+		//DETACH перед DROP, чтобы не держать блокировку родительской таблицы дольше нужного
+		if _, err := r.conn.Exec(ctx, `ALTER TABLE location_checks DETACH PARTITION `+pgx.Identifier{name}.Sanitize()); err != nil {
+			return dropped, fmt.Errorf("ошибка отсоединения секции %s: %w", name, err)
+		}
+		if _, err := r.conn.Exec(ctx, `DROP TABLE `+pgx.Identifier{name}.Sanitize()); err != nil {
+			return dropped, fmt.Errorf("ошибка удаления секции %s: %w", name, err)
+		}
+		dropped = append(dropped, name)
+	}
+	return dropped, nil
+}
+
+// EnsureCheckPartitions заранее создаёт дневные секции на days дней начиная с дня from,
+// чтобы новые проверки не попадали в секцию по умолчанию
+func (r *PostgresStorage) EnsureCheckPartitions(ctx context.Context, from time.Time, days int) error {
+	day := from.UTC().Truncate(24 * time.Hour)
+	for range days {
+		next := day.AddDate(0, 0, 1)
+		query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF location_checks FOR VALUES FROM ('%s') TO ('%s')`,
+			pgx.Identifier{checkPartitionPrefix + day.Format(checkPartitionDay)}.Sanitize(), day.Format(time.RFC3339), next.Format(time.RFC3339))
+		if _, err := r.conn.Exec(ctx, query); err != nil {
+			return fmt.Errorf("ошибка создания секции location_checks за %s: %w", day.Format(time.DateOnly), err)
+		}
+		day = next
+	}
+	return nil
+}
+
+// PartitionChecks превращает location_checks в таблицу, секционированную по checked_at. Все существующие строки
+// становятся одной секцией до boundary (начало дня в UTC), новые дневные секции создаются начиная с boundary
+func (r *PostgresStorage) PartitionChecks(ctx context.Context, boundary time.Time) error {
+	boundary = boundary.UTC().Truncate(24 * time.Hour)
+	legacy := pgx.Identifier{checkLegacyPrefix + boundary.Format(checkPartitionDay)}.Sanitize()
+	statements := []string{
+		`LOCK TABLE location_checks IN ACCESS EXCLUSIVE MODE`,
+		`ALTER TABLE location_checks RENAME TO ` + legacy,
+		//последовательность id переходит к новой таблице, иначе она удалится вместе со старой секцией
+		`ALTER SEQUENCE location_checks_id_seq OWNED BY NONE`,
+		`UPDATE ` + legacy + ` SET checked_at = CURRENT_TIMESTAMP WHERE checked_at IS NULL`,
+		`ALTER TABLE ` + legacy + ` ALTER COLUMN checked_at SET NOT NULL`,
+		`CREATE TABLE location_checks (
+            id           BIGINT NOT NULL DEFAULT nextval('location_checks_id_seq'),
+            user_id      VARCHAR(255) NOT NULL,
+            lat          DOUBLE PRECISION NOT NULL,
+            lon          DOUBLE PRECISION NOT NULL,
+            incident_ids UUID[] DEFAULT '{}',
+            checked_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            tenant_id    UUID NOT NULL DEFAULT '` + domain.DefaultTenantID.String() + `' REFERENCES tenants(id),
+            PRIMARY KEY (id, checked_at)
+        ) PARTITION BY RANGE (checked_at)`,
+		`ALTER SEQUENCE location_checks_id_seq OWNED BY location_checks.id`,
+		`ALTER TABLE location_checks ATTACH PARTITION ` + legacy + ` FOR VALUES FROM (MINVALUE) TO ('` + boundary.Format(time.RFC3339) + `')`,
+		//строки вне заранее созданных секций попадают сюда, а не теряются с ошибкой вставки
+		`CREATE TABLE location_checks_default PARTITION OF location_checks DEFAULT`,
+		`CREATE INDEX IF NOT EXISTS idx_location_checks_incident_ids_p ON location_checks USING GIN (incident_ids)`,
+		`CREATE INDEX IF NOT EXISTS idx_location_checks_time_p ON location_checks(checked_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_location_checks_tenant_time_p ON location_checks(tenant_id, checked_at)`,
+	}
+	return r.inTx(ctx, func(tx pgx.Tx) error {
+		for _, stmt := range statements {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return fmt.Errorf("ошибка секционирования location_checks: %w", err)
+			}
+		}
+		return nil
+	})
+}
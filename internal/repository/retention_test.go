@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestStatsIncludeRolledUpHours - после сворачивания и удаления сырых строк статистика и тепловая карта
+// за старые часы берутся из почасовых итогов, а свежие проверки по-прежнему из location_checks
+func TestStatsIncludeRolledUpHours(t *testing.T) {
+	db := newTestStorage(t)
+	ctx := newTestTenant(t, db)
+	tenantID := domain.TenantIDFromContext(ctx)
+
+	incident := &domain.Incident{
+		Title: "Пожар", Category: domain.CategoryFire, Severity: 2,
+		Latitude: 55.75, Longitude: 37.61, RadiusMeters: 500,
+		Status: domain.StatusActive, CreatedAt: time.Now(),
+	}
+	id, err := db.Create(ctx, incident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	old := now.Add(-3 * time.Hour)
+	insert := `INSERT INTO location_checks (user_id, lat, lon, incident_ids, tenant_id, checked_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	for _, check := range []struct {
+		user      string
+		lat, lon  float64
+		incidents []uuid.UUID
+		at        time.Time
+	}{
+		{"old", 55.75, 37.61, []uuid.UUID{id}, old},
+		{"new", 55.75, 37.61, []uuid.UUID{id}, now},
+		//проверка вне опасных зон тоже должна остаться на тепловой карте после сворачивания
+		{"outside", 10.5, 10.5, []uuid.UUID{}, old},
+	} {
+		if _, err := db.conn.Exec(ctx, insert, check.user, check.lat, check.lon, check.incidents, tenantID, check.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := now.Add(-time.Hour)
+	if _, err := db.RollupChecks(ctx, before, 0.001, 0.001); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DeleteChecks(ctx, before, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	from := old.Truncate(time.Hour)
+	to := now.Add(time.Minute)
+	stats, err := db.GetStats(ctx, domain.StatsQuery{From: from, To: to, IncidentID: &id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].UserCount != 2 || !stats[0].Approximate {
+		t.Fatalf("статистика без свёрнутых часов: %+v", stats)
+	}
+	//свежие часы считаются точно
+	fresh, err := db.GetStats(ctx, domain.StatsQuery{From: before, To: to, IncidentID: &id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fresh) != 1 || fresh[0].UserCount != 1 || fresh[0].Approximate {
+		t.Fatalf("статистика по сырым строкам: %+v", fresh)
+	}
+	series, err := db.GetStatsSeries(ctx, domain.StatsQuery{From: from, To: to, Bucket: domain.BucketHour, IncidentID: &id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points := series[id.String()]; len(points) != 2 {
+		t.Fatalf("ряд без свёрнутого часа: %+v", points)
+	}
+	cells, err := db.GetHeatmap(ctx, from, to, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != 2 {
+		t.Fatalf("тепловая карта без свёрнутых часов: %+v", cells)
+	}
+	for _, cell := range cells {
+		inZone := cell.Latitude > 50
+		switch {
+		case inZone && (cell.Checks != 2 || cell.InDangerChecks != 2):
+			t.Fatalf("ячейка инцидента: %+v", cell)
+		case !inZone && (cell.Checks != 1 || cell.InDangerChecks != 0):
+			t.Fatalf("ячейка вне опасных зон: %+v", cell)
+		case !cell.Approximate:
+			t.Fatalf("ячейка со свёрнутыми часами не помечена как приблизительная: %+v", cell)
+		}
+	}
+}
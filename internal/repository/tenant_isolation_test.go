@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestTenantIsolation - организация A не видит и не меняет данные организации B ни по одному пути
+func TestTenantIsolation(t *testing.T) {
+	db := newTestStorage(t)
+	ctxA, ctxB := newTestTenant(t, db), newTestTenant(t, db)
+
+	incident := &domain.Incident{
+		Title: "Пожар", Category: domain.CategoryFire, Severity: 2,
+		Latitude: 55.75, Longitude: 37.61, RadiusMeters: 500,
+		Status: domain.StatusActive, CreatedAt: time.Now(),
+	}
+	id, err := db.Create(ctxB, incident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incident.ID = id
+	if err := db.SaveCheck(ctxB, "user-b", 55.75, 37.61, []uuid.UUID{id}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("инцидент по ID", func(t *testing.T) {
+		_, err := db.GetByID(ctxA, id)
+		assertNotFound(t, "GetByID", err)
+	})
+	t.Run("список инцидентов", func(t *testing.T) {
+		list, err := db.Get(ctxA, 55.75, 37.61, 10, 0, 0, domain.IncidentFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 0 {
+			t.Fatalf("в списке организации A чужие инциденты: %d", len(list))
+		}
+	})
+	t.Run("изменение", func(t *testing.T) {
+		changed := *incident
+		changed.Title = "Чужой"
+		assertNotFound(t, "Update", db.Update(ctxA, &changed))
+	})
+	t.Run("удаление", func(t *testing.T) {
+		//удаление инцидента - это переход в resolved или archived
+		_, err := db.SetStatus(ctxA, id, domain.StatusActive, domain.StatusResolved)
+		assertNotFound(t, "SetStatus", err)
+	})
+	t.Run("история", func(t *testing.T) {
+		versions, err := db.GetHistory(ctxA, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 0 {
+			t.Fatalf("организации A видна история чужого инцидента: %d версий", len(versions))
+		}
+		_, err = db.GetVersion(ctxA, id, 1)
+		assertNotFound(t, "GetVersion", err)
+	})
+	t.Run("статистика", func(t *testing.T) {
+		q := domain.StatsQuery{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Minute), Bucket: domain.BucketHour}
+		stats, err := db.GetStats(ctxA, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		series, err := db.GetStatsSeries(ctxA, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats) != 0 || len(series) != 0 {
+			t.Fatalf("в статистике организации A чужие проверки: %v %v", stats, series)
+		}
+		q.IncidentID = &id
+		stats, err = db.GetStats(ctxA, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats) != 0 {
+			t.Fatalf("статистика чужого инцидента по ID: %v", stats)
+		}
+	})
+	t.Run("тепловая карта", func(t *testing.T) {
+		cells, err := db.GetHeatmap(ctxA, time.Now().Add(-time.Hour), time.Now().Add(time.Minute), 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cells) != 0 {
+			t.Fatalf("на тепловой карте организации A чужие проверки: %v", cells)
+		}
+	})
+
+	//организация B при этом видит всё своё
+	if _, err := db.GetByID(ctxB, id); err != nil {
+		t.Fatalf("организация B не видит свой инцидент: %v", err)
+	}
+}
+
+func TestAPIKeysTenantIsolation(t *testing.T) {
+	db := newTestStorage(t)
+	ctxA, ctxB := newTestTenant(t, db), newTestTenant(t, db)
+
+	key := &domain.APIKey{TenantID: domain.TenantIDFromContext(ctxB), Name: "b", Prefix: strings.ReplaceAll(uuid.NewString(), "-", "")[:12], Scopes: []domain.Scope{domain.ScopeIncidentsRead}}
+	if err := db.CreateAPIKey(ctxB, key, []byte("hash")); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.ListAPIKeys(ctxA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("организации A видны чужие ключи: %d", len(keys))
+	}
+	if err := db.RevokeAPIKey(ctxA, key.ID, time.Now()); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Fatalf("организация A отозвала чужой ключ: %v", err)
+	}
+}
+
+func TestAuditTenantIsolation(t *testing.T) {
+	db := newTestStorage(t)
+	ctxA, ctxB := newTestTenant(t, db), newTestTenant(t, db)
+
+	entry := &domain.AuditEntry{TenantID: domain.TenantIDFromContext(ctxB), Actor: "b", Method: "POST", Route: "/api/v1/incidents/", Status: 201}
+	if err := db.SaveAudit(ctxB, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := db.ListAudit(ctxA, domain.AuditFilter{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("в журнале организации A чужие записи: %d", len(entries))
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrTenantExists - slug уже занят другой организацией
+var ErrTenantExists = domain.Conflict(domain.CodeTenantExists, "организация с таким slug уже существует")
+
+// pgUniqueViolation - код ошибки Postgres при нарушении уникального индекса
+const pgUniqueViolation = "23505"
+
+type TenantRepository interface {
+	CreateTenant(ctx context.Context, req domain.TenantRequest) (*domain.Tenant, error)
+	GetTenant(ctx context.Context, id uuid.UUID) (*domain.Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (*domain.Tenant, error)
+	ListTenants(ctx context.Context) ([]*domain.Tenant, error)
+	UpdateTenant(ctx context.Context, id uuid.UUID, req domain.TenantRequest) (*domain.Tenant, error)
+}
+
+const tenantColumns = `id, slug, name, warning_zone, stats_window_minutes, webhook_url, webhook_retries, webhook_timeout, created_at`
+
+func scanTenant(row pgx.Row) (*domain.Tenant, error) {
+	var t domain.Tenant
+	err := row.Scan(&t.ID, &t.Slug, &t.Name, &t.WarningZone, &t.StatsWindowMinutes, &t.WebhookURL, &t.WebhookRetries, &t.WebhookTimeout, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// tenantError переводит ошибки базы в ошибки репозитория организаций
+func tenantError(err error, msg string) error {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return domain.ErrTenantNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation:
+		return ErrTenantExists
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// CreateTenant создаёт организацию, ID и время создания проставляет база
+func (r *PostgresStorage) CreateTenant(ctx context.Context, req domain.TenantRequest) (*domain.Tenant, error) {
+	query := `
+        INSERT INTO tenants (slug, name, warning_zone, stats_window_minutes, webhook_url, webhook_retries, webhook_timeout)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING ` + tenantColumns
+	t, err := scanTenant(r.conn.QueryRow(ctx, query,
+		req.Slug, req.Name, req.WarningZone, req.StatsWindowMinutes, req.WebhookURL, req.WebhookRetries, req.WebhookTimeout,
+	))
+	if err != nil {
+		return nil, tenantError(err, "ошибка создания организации")
+	}
+	return t, nil
+}
+
+func (r *PostgresStorage) GetTenant(ctx context.Context, id uuid.UUID) (*domain.Tenant, error) {
+	t, err := scanTenant(r.conn.QueryRow(ctx, `SELECT `+tenantColumns+` FROM tenants WHERE id = $1`, id))
+	if err != nil {
+		return nil, tenantError(err, "ошибка получения организации")
+	}
+	return t, nil
+}
+
+func (r *PostgresStorage) GetTenantBySlug(ctx context.Context, slug string) (*domain.Tenant, error) {
+	t, err := scanTenant(r.conn.QueryRow(ctx, `SELECT `+tenantColumns+` FROM tenants WHERE slug = $1`, slug))
+	if err != nil {
+		return nil, tenantError(err, "ошибка получения организации")
+	}
+	return t, nil
+}
+
+func (r *PostgresStorage) ListTenants(ctx context.Context) ([]*domain.Tenant, error) {
+	rows, err := r.conn.Query(ctx, `SELECT `+tenantColumns+` FROM tenants ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения организаций: %w", err)
+	}
+	defer rows.Close()
+
+	tenants := make([]*domain.Tenant, 0)
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения организации: %w", err)
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// UpdateTenant перезаписывает slug, название и настройки организации целиком, пустые настройки сбрасываются к значениям сервиса
+func (r *PostgresStorage) UpdateTenant(ctx context.Context, id uuid.UUID, req domain.TenantRequest) (*domain.Tenant, error) {
+	query := `
+        UPDATE tenants SET slug=$2, name=$3, warning_zone=$4, stats_window_minutes=$5, webhook_url=$6, webhook_retries=$7, webhook_timeout=$8
+        WHERE id = $1
+        RETURNING ` + tenantColumns
+	t, err := scanTenant(r.conn.QueryRow(ctx, query,
+		id, req.Slug, req.Name, req.WarningZone, req.StatsWindowMinutes, req.WebhookURL, req.WebhookRetries, req.WebhookTimeout,
+	))
+	if err != nil {
+		return nil, tenantError(err, "ошибка изменения организации")
+	}
+	return t, nil
+}
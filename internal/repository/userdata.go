@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UserDataRepository - доставки вебхуков и данные одного пользователя для выгрузки и удаления по его запросу
+type UserDataRepository interface {
+	SaveDelivery(ctx context.Context, d *domain.WebhookDelivery) error
+	UserChecks(ctx context.Context, userIDs []string) ([]*domain.LocationCheck, error)
+	UserDeliveries(ctx context.Context, userIDs []string) ([]*domain.WebhookDelivery, error)
+	EraseUser(ctx context.Context, userIDs []string) (checks int64, deliveries int64, err error)
+}
+
+// SaveDelivery записывает итог доставки вебхука в организации d.TenantID
+func (r *PostgresStorage) SaveDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	query := `
+        INSERT INTO webhook_deliveries (tenant_id, event, user_id, incident_id, detected_at, url, delivered, error, request_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at`
+	err := r.conn.QueryRow(ctx, query,
+		d.TenantID, d.Event, d.UserID, d.IncidentID, d.DetectedAt, d.URL, d.Delivered, d.Error, d.RequestID,
+	).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка записи доставки вебхука: %w", err)
+	}
+	return nil
+}
+
+// UserChecks отдаёт все проверки координат пользователя в организации из контекста, старые первыми.
+// userIDs - все значения, под которыми хранится пользователь (настоящий ID и псевдоним)
+func (r *PostgresStorage) UserChecks(ctx context.Context, userIDs []string) ([]*domain.LocationCheck, error) {
+	query := `
+        SELECT id, user_id, lat, lon, COALESCE(incident_ids, '{}'), checked_at
+        FROM location_checks
+        WHERE tenant_id = $1 AND user_id = ANY($2)
+        ORDER BY checked_at, id`
+	rows, err := r.conn.Query(ctx, query, domain.TenantIDFromContext(ctx), userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения проверок пользователя: %w", err)
+	}
+	checks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*domain.LocationCheck, error) {
+		var c domain.LocationCheck
+		err := row.Scan(&c.ID, &c.UserID, &c.Latitude, &c.Longitude, &c.IncidentIDs, &c.CheckedAt)
+		return &c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения проверок пользователя: %w", err)
+	}
+	return checks, nil
+}
+
+// UserDeliveries отдаёт все доставки вебхуков о пользователе в организации из контекста, старые первыми
+func (r *PostgresStorage) UserDeliveries(ctx context.Context, userIDs []string) ([]*domain.WebhookDelivery, error) {
+	query := `
+        SELECT id, tenant_id, event, user_id, incident_id, detected_at, url, delivered, error, request_id, created_at
+        FROM webhook_deliveries
+        WHERE tenant_id = $1 AND user_id = ANY($2)
+        ORDER BY created_at, id`
+	rows, err := r.conn.Query(ctx, query, domain.TenantIDFromContext(ctx), userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения доставок вебхуков пользователя: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*domain.WebhookDelivery, error) {
+		var d domain.WebhookDelivery
+		err := row.Scan(&d.ID, &d.TenantID, &d.Event, &d.UserID, &d.IncidentID, &d.DetectedAt, &d.URL, &d.Delivered, &d.Error, &d.RequestID, &d.CreatedAt)
+		return &d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения доставок вебхуков пользователя: %w", err)
+	}
+	return deliveries, nil
+}
+
+// EraseUser удаляет проверки и доставки вебхуков пользователя в организации из контекста одной транзакцией.
+// Почасовые итоги остаются: в них нет ID пользователей
+func (r *PostgresStorage) EraseUser(ctx context.Context, userIDs []string) (int64, int64, error) {
+	var checks, deliveries int64
+	tenantID := domain.TenantIDFromContext(ctx)
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM location_checks WHERE tenant_id = $1 AND user_id = ANY($2)`, tenantID, userIDs)
+		if err != nil {
+			return err
+		}
+		checks = tag.RowsAffected()
+		tag, err = tx.Exec(ctx, `DELETE FROM webhook_deliveries WHERE tenant_id = $1 AND user_id = ANY($2)`, tenantID, userIDs)
+		if err != nil {
+			return err
+		}
+		deliveries = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("ошибка удаления данных пользователя: %w", err)
+	}
+	return checks, deliveries, nil
+}
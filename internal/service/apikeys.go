@@ -0,0 +1,160 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// формат ключа: rc_<prefix>_<secret>, prefix хранится открыто для поиска, secret только в виде хэша
+const (
+	apiKeyTag        = "rc"
+	apiKeyPrefixLen  = 6  //байт, в ключе 12 hex символов
+	apiKeySecretLen  = 32 //байт
+	rootAPIKeyName   = "root"
+	maxAPIKeyNameLen = 255
+)
+
+// APIKeyService выпускает, проверяет и отзывает API ключи
+type APIKeyService struct {
+	repo    repository.APIKeyRepository
+	rootKey []byte //хэш ключа из API_KEY, nil - корневого ключа нет
+}
+
+// NewAPIKeyService принимает корневой ключ из конфига: он даёт все права во всех организациях
+// и нужен, чтобы завести организации и выпустить первые ключи
+func NewAPIKeyService(repo repository.APIKeyRepository, rootKey string) *APIKeyService {
+	s := &APIKeyService{repo: repo}
+	if rootKey != "" {
+		s.rootKey = hashAPIKey(rootKey)
+	}
+	return s
+}
+
+// hashAPIKey - у ключа 256 бит случайности, поэтому медленный хэш вроде bcrypt не нужен
+func hashAPIKey(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// Authenticate находит ключ по токену из заголовка. Сравниваются хэши фиксированной длины
+// через subtle.ConstantTimeCompare, чтобы по времени ответа нельзя было подобрать ключ
+func (s *APIKeyService) Authenticate(ctx context.Context, token string) (*domain.APIKey, error) {
+	hash := hashAPIKey(token)
+	if s.rootKey != nil && subtle.ConstantTimeCompare(hash, s.rootKey) == 1 {
+		return &domain.APIKey{Name: rootAPIKeyName, Scopes: domain.AllScopes}, nil
+	}
+
+	prefix, ok := parseAPIKeyPrefix(token)
+	if !ok {
+		return nil, domain.ErrInvalidAPIKey
+	}
+	key, stored, err := s.repo.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if subtle.ConstantTimeCompare(hash, stored) != 1 || !key.IsUsableAt(now) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	//время использования не критично для запроса, ошибку только логируем.
+	//Ключ только что прочитан вместе с last_used_at, поэтому свежую отметку не трогаем и в базу не ходим
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= repository.LastUsedPrecision {
+		if err := s.repo.TouchAPIKey(ctx, key.ID, now); err != nil {
+			slog.WarnContext(ctx, "не удалось обновить время использования API ключа", slog.Any("error", err))
+		}
+	}
+	return key, nil
+}
+
+// parseAPIKeyPrefix достаёт открытый префикс из токена вида rc_<prefix>_<secret>
+func parseAPIKeyPrefix(token string) (string, bool) {
+	parts := strings.SplitN(token, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyTag || len(parts[1]) != apiKeyPrefixLen*2 {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// Issue выпускает новый ключ, токен возвращается один раз
+func (s *APIKeyService) Issue(ctx context.Context, req domain.IssueAPIKeyRequest) (domain.IssuedAPIKey, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxAPIKeyNameLen {
+		return domain.IssuedAPIKey{}, domain.Invalid(domain.CodeInvalidKeyName, "имя ключа должно быть от 1 до %d символов", maxAPIKeyNameLen).OnField("name")
+	}
+	if len(req.Scopes) == 0 {
+		return domain.IssuedAPIKey{}, domain.Invalid(domain.CodeScopesEmpty, "ключу нужно выдать хотя бы одно право").OnField("scopes")
+	}
+	//ключ организации не может выдать больше прав, чем есть у него самого
+	issuer := domain.APIKeyFromContext(ctx)
+	for _, scope := range req.Scopes {
+		if !scope.IsValid() {
+			return domain.IssuedAPIKey{}, domain.Invalid(domain.CodeUnknownScope, "неизвестное право %q", scope).OnField("scopes")
+		}
+		//управление организациями открывает данные всех организаций, поэтому это право остаётся только у корневого ключа
+		if scope == domain.ScopeTenantsAdmin {
+			return domain.IssuedAPIKey{}, domain.Forbidden(domain.CodeScopeNotGrantable, "право %q нельзя выдать API ключу", scope).OnField("scopes")
+		}
+		if issuer != nil && !issuer.HasScope(scope) {
+			return domain.IssuedAPIKey{}, domain.Forbidden(domain.CodeScopeNotHeld, "нельзя выдать право %q, которого нет у текущего ключа", scope).OnField("scopes")
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return domain.IssuedAPIKey{}, domain.Invalid(domain.CodeExpiresInPast, "expires_at должен быть в будущем").OnField("expires_at")
+	}
+
+	prefix := make([]byte, apiKeyPrefixLen)
+	secret := make([]byte, apiKeySecretLen)
+	if _, err := rand.Read(prefix); err != nil {
+		return domain.IssuedAPIKey{}, fmt.Errorf("ошибка генерации API ключа: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return domain.IssuedAPIKey{}, fmt.Errorf("ошибка генерации API ключа: %w", err)
+	}
+
+	//ключ выпускается в организации запроса, корневой ключ выбирает её заголовком X-Tenant
+	issued := domain.IssuedAPIKey{
+		APIKey: domain.APIKey{
+			TenantID:  domain.TenantIDFromContext(ctx),
+			Name:      req.Name,
+			Prefix:    hex.EncodeToString(prefix),
+			Scopes:    req.Scopes,
+			ExpiresAt: req.ExpiresAt,
+		},
+	}
+	issued.Token = apiKeyTag + "_" + issued.Prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	if err := s.repo.CreateAPIKey(ctx, &issued.APIKey, hashAPIKey(issued.Token)); err != nil {
+		return domain.IssuedAPIKey{}, err
+	}
+	return issued, nil
+}
+
+// List отдаёт все ключи организации запроса без секретов
+func (s *APIKeyService) List(ctx context.Context) ([]*domain.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+// Revoke отзывает ключ организации запроса, он перестаёт работать сразу, без перезапуска сервиса
+func (s *APIKeyService) Revoke(ctx context.Context, id string) error {
+	keyID, err := uuid.Parse(id)
+	if err != nil {
+		return errInvalidID(id)
+	}
+	return s.repo.RevokeAPIKey(ctx, keyID, time.Now())
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeKeys отдаёт один ключ и считает обновления last_used_at
+type fakeKeys struct {
+	repository.APIKeyRepository
+	key     domain.APIKey
+	hash    []byte
+	touches int
+}
+
+func (f *fakeKeys) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, []byte, error) {
+	if prefix != f.key.Prefix {
+		return nil, nil, repository.ErrAPIKeyNotFound
+	}
+	key := f.key
+	return &key, f.hash, nil
+}
+
+func (f *fakeKeys) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	f.touches++
+	f.key.LastUsedAt = &at
+	return nil
+}
+
+func TestAuthenticateTouchesKeyOncePerInterval(t *testing.T) {
+	const token = "rc_0123456789ab_secret"
+	repo := &fakeKeys{key: domain.APIKey{ID: uuid.New(), Prefix: "0123456789ab", Scopes: domain.AllScopes}, hash: hashAPIKey(token)}
+	s := NewAPIKeyService(repo, "")
+
+	for range 5 {
+		if _, err := s.Authenticate(context.Background(), token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.touches != 1 {
+		t.Fatalf("last_used_at обновлён %d раз, ожидали один", repo.touches)
+	}
+
+	stale := time.Now().Add(-repository.LastUsedPrecision)
+	repo.key.LastUsedAt = &stale
+	if _, err := s.Authenticate(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+	if repo.touches != 2 {
+		t.Fatalf("устаревший last_used_at не обновлён")
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	//defaultAuditLimit - сколько записей журнала отдаётся без явного limit
+	defaultAuditLimit = 50
+	//auditExportPage - по сколько записей читаем журнал при выгрузке в CSV
+	auditExportPage = domain.MaxAuditLimit
+)
+
+// AuditService записывает действия операторов в журнал и отдаёт его с фильтрами
+type AuditService struct {
+	repo       repository.AuditRepository
+	pseudonyms *Privacy
+}
+
+// pseudonyms - ключ псевдонимов пользователей. Журнал изменить нельзя, поэтому пользователь попадает в него
+// только псевдонимом независимо от режима приватности и остаётся неузнаваемым после удаления его данных
+func NewAuditService(repo repository.AuditRepository, pseudonyms *Privacy) *AuditService {
+	return &AuditService{repo: repo, pseudonyms: pseudonyms}
+}
+
+// Record сохраняет запись журнала. Действие к этому моменту уже выполнено,
+// поэтому ошибку записи не возвращаем клиенту, а только логируем
+func (s *AuditService) Record(ctx context.Context, entry *domain.AuditEntry) {
+	if entry.Target != "" {
+		entry.Target = s.pseudonyms.UserID(entry.Target)
+	}
+	if err := s.repo.SaveAudit(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "не удалось записать действие в журнал", slog.Any("error", err),
+			slog.String("route", entry.Route), slog.Int("status", entry.Status))
+	}
+}
+
+// List отдаёт одну страницу журнала организации запроса
+func (s *AuditService) List(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	filter.Targets = s.storedTargets(filter.Targets)
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	filter.Limit = min(filter.Limit, domain.MaxAuditLimit)
+	return s.repo.ListAudit(ctx, filter)
+}
+
+// Export отдаёт в fn все записи журнала под фильтром, читая их страницами, чтобы не держать весь журнал в памяти.
+// limit и offset фильтра при выгрузке не учитываются
+func (s *AuditService) Export(ctx context.Context, filter domain.AuditFilter, fn func(*domain.AuditEntry) error) error {
+	filter.Limit, filter.Offset = 0, 0
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	filter.Targets = s.storedTargets(filter.Targets)
+	//новые записи появляются в начале выборки и сдвигали бы страницы, поэтому фиксируем верхнюю границу на момент начала выгрузки
+	if filter.To.IsZero() {
+		filter.To = time.Now()
+	}
+	filter.Limit = auditExportPage
+	for {
+		page, err := s.repo.ListAudit(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, entry := range page {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if len(page) < filter.Limit {
+			return nil
+		}
+		filter.Offset += len(page)
+	}
+}
+
+// storedTargets ищет пользователя в журнале по псевдониму, а также под настоящим ID, если такие записи остались
+// с тех пор, когда target сохранялся как есть
+func (s *AuditService) storedTargets(targets []string) []string {
+	stored := make([]string, 0, len(targets))
+	for _, target := range targets {
+		stored = append(stored, s.pseudonyms.StoredUserIDs(target)...)
+	}
+	return stored
+}
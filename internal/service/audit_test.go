@@ -0,0 +1,46 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"context"
+	"slices"
+	"testing"
+)
+
+// memAudit хранит журнал в памяти и запоминает последний фильтр
+type memAudit struct {
+	repository.AuditRepository
+	saved  []*domain.AuditEntry
+	filter domain.AuditFilter
+}
+
+func (m *memAudit) SaveAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	m.saved = append(m.saved, entry)
+	return nil
+}
+
+func (m *memAudit) ListAudit(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	m.filter = filter
+	return nil, nil
+}
+
+// TestAuditStoresPseudonymizedTarget - журнал нельзя изменить, поэтому настоящий user_id в него не попадает никогда
+func TestAuditStoresPseudonymizedTarget(t *testing.T) {
+	repo := &memAudit{}
+	privacy := NewPrivacy("0123456789abcdef0123456789abcdef", 3)
+	s := NewAuditService(repo, privacy)
+
+	s.Record(context.Background(), &domain.AuditEntry{Method: "DELETE", Target: "user-42"})
+	if got := repo.saved[0].Target; got != privacy.UserID("user-42") {
+		t.Fatalf("в журнал записан target %q, ожидали псевдоним", got)
+	}
+
+	//оператор ищет по настоящему user_id, а находятся записи и под псевдонимом, и старые под настоящим ID
+	if _, err := s.List(context.Background(), domain.AuditFilter{Targets: []string{"user-42"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(repo.filter.Targets, privacy.StoredUserIDs("user-42")) {
+		t.Fatalf("фильтр журнала %v", repo.filter.Targets)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Pinger - зависимость, доступность которой можно проверить
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// QueueMeter отдаёт длину очереди вебхуков
+type QueueMeter interface {
+	QueueLength(ctx context.Context) (int64, error)
+}
+
+// HealthService проверяет готовность сервиса: доступность postgres и redis, очередь и работу webhook воркера
+type HealthService struct {
+	postgres     Pinger
+	redis        Pinger
+	queue        QueueMeter
+	heartbeat    func() time.Time
+	timeout      time.Duration
+	heartbeatAge time.Duration
+}
+
+func NewHealthService(postgres, redis Pinger, queue QueueMeter, heartbeat func() time.Time, timeoutSec, heartbeatAgeSec int) *HealthService {
+	return &HealthService{
+		postgres:     postgres,
+		redis:        redis,
+		queue:        queue,
+		heartbeat:    heartbeat,
+		timeout:      time.Duration(timeoutSec) * time.Second,
+		heartbeatAge: time.Duration(heartbeatAgeSec) * time.Second,
+	}
+}
+
+// Ready проверяет все зависимости параллельно, каждую со своим таймаутом, и собирает общий отчёт
+func (h *HealthService) Ready(ctx context.Context) domain.HealthReport {
+	checks := map[string]func(ctx context.Context) (any, error){
+		"postgres": func(ctx context.Context) (any, error) { return nil, h.postgres.Ping(ctx) },
+		"redis":    func(ctx context.Context) (any, error) { return nil, h.redis.Ping(ctx) },
+		"webhook_queue": func(ctx context.Context) (any, error) {
+			depth, err := h.queue.QueueLength(ctx)
+			return map[string]any{"depth": depth}, err
+		},
+		"webhook_worker": func(context.Context) (any, error) { return h.checkWorker() },
+	}
+
+	report := domain.HealthReport{Status: domain.HealthOK, Components: make(map[string]domain.ComponentHealth, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			component := h.run(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = component
+			if component.Status != domain.HealthOK {
+				report.Status = domain.HealthFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// run выполняет одну проверку с таймаутом и замеряет её время
+func (h *HealthService) run(ctx context.Context, check func(ctx context.Context) (any, error)) domain.ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check(ctx)
+	component := domain.ComponentHealth{
+		Status:    domain.HealthOK,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Details:   details,
+	}
+	if err != nil {
+		component.Status = domain.HealthFail
+		component.Error = err.Error()
+	}
+	return component
+}
+
+// checkWorker считает воркер живым, если он отмечался в heartbeat не раньше heartbeatAge назад
+func (h *HealthService) checkWorker() (any, error) {
+	last := h.heartbeat()
+	if last.IsZero() {
+		return nil, errors.New("webhook воркер ещё не запущен")
+	}
+	age := time.Since(last)
+	details := map[string]any{"heartbeat_age_sec": int64(age.Seconds())}
+	if age > h.heartbeatAge {
+		return details, fmt.Errorf("webhook воркер не отвечает %v", age.Truncate(time.Second))
+	}
+	return details, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/tracing"
+	"context"
+	"math"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+const (
+	minHeatmapPrecision = 1
+	maxHeatmapPrecision = 8 //ячейка ~38x19 метров, мельче смысла нет - это уже отдельные дома
+)
+
+// geohashCellSize отдаёт размер ячейки geohash заданной точности в градусах:
+// из 5*precision бит на долготу приходится ceil, а на широту floor от половины
+func geohashCellSize(precision int) (lat, lon float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Exp2(float64(latBits)), 360 / math.Exp2(float64(lonBits))
+}
+
+// GetHeatmap собирает тепловую карту проверок за период в виде GeoJSON, каждая ячейка geohash - отдельный полигон
+func (i *IncidentService) GetHeatmap(ctx context.Context, STATS_TIME_WINDOW_MINUTES int, from, to time.Time, precision int) (domain.FeatureCollection, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.GetHeatmap")
+	defer span.End()
+
+	//у организации может быть своё окно статистики по умолчанию
+	STATS_TIME_WINDOW_MINUTES = domain.TenantFromContext(ctx).StatsWindowOr(STATS_TIME_WINDOW_MINUTES)
+
+	if precision < minHeatmapPrecision || precision > maxHeatmapPrecision {
+		return domain.FeatureCollection{}, domain.Invalid(domain.CodeInvalidPrecision, "точность geohash должна быть в диапазоне от %d до %d", minHeatmapPrecision, maxHeatmapPrecision).OnField("precision")
+	}
+	//период по умолчанию такой же, как у статистики
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-time.Duration(STATS_TIME_WINDOW_MINUTES) * time.Minute)
+	}
+	if !to.After(from) {
+		return domain.FeatureCollection{}, domain.Invalid(domain.CodeInvalidPeriod, "конец периода должен быть позже начала").OnField("to")
+	}
+
+	cellLat, cellLon := geohashCellSize(precision)
+	cells, err := i.repo.GetHeatmap(ctx, from, to, cellLat, cellLon)
+	if err != nil {
+		return domain.FeatureCollection{}, err
+	}
+
+	collection := domain.FeatureCollection{Type: "FeatureCollection", Features: make([]domain.Feature, 0, len(cells))}
+	for _, cell := range cells {
+		//по центру ячейки однозначно восстанавливаем её geohash и границы
+		cell.Geohash = geohash.EncodeWithPrecision(cell.Latitude, cell.Longitude, uint(precision))
+		box := geohash.BoundingBox(cell.Geohash)
+		collection.Features = append(collection.Features, domain.Feature{
+			Type: "Feature",
+			Geometry: domain.Geometry{
+				Type: "Polygon",
+				Coordinates: [][][2]float64{{
+					{box.MinLng, box.MinLat},
+					{box.MaxLng, box.MinLat},
+					{box.MaxLng, box.MaxLat},
+					{box.MinLng, box.MaxLat},
+					{box.MinLng, box.MinLat},
+				}},
+			},
+			Properties: cell,
+		})
+	}
+	return collection, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+)
+
+// newTestRedis поднимает redis в памяти и отдаёт репозиторий поверх него
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, repository.RedisRepository) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	rdb, err := repository.RedisConnection(context.Background(), m.Addr())
+	if err != nil {
+		t.Fatalf("подключение к miniredis: %v", err)
+	}
+	t.Cleanup(func() { _ = rdb.Close() })
+	return m, rdb
+}
+
+// tenantCtx - контекст запроса организации с указанным ID
+func tenantCtx(id uuid.UUID) context.Context {
+	return domain.WithTenant(context.Background(), &domain.Tenant{ID: id, Slug: "t-" + id.String()[:8]})
+}
+
+// fakeRepo - хранилище инцидентов в памяти, неиспользуемые методы остаются от встроенного nil интерфейса
+type fakeRepo struct {
+	repository.IncidentRepository
+	incidents  map[uuid.UUID]*domain.Incident
+	stats      []domain.StatisticResponse
+	exactCalls int
+	statusSets int //сколько раз менялся статус
+}
+
+func newFakeRepo(incidents ...*domain.Incident) *fakeRepo {
+	f := &fakeRepo{incidents: make(map[uuid.UUID]*domain.Incident)}
+	for _, i := range incidents {
+		f.incidents[i.ID] = i
+	}
+	return f
+}
+
+// GetByID, как и postgres, не видит инциденты другой организации
+func (f *fakeRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Incident, error) {
+	i, ok := f.incidents[id]
+	if !ok || i.TenantID != domain.TenantIDFromContext(ctx) {
+		return nil, domain.NotFound(domain.CodeIncidentNotFound, "инцидент с ID %s не найден", id)
+	}
+	copied := *i
+	return &copied, nil
+}
+
+func (f *fakeRepo) SetStatus(ctx context.Context, id uuid.UUID, from, to domain.IncidentStatus) (*domain.Incident, error) {
+	i, err := f.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	f.statusSets++
+	i.Status = to
+	f.incidents[id].Status = to
+	return i, nil
+}
+
+func (f *fakeRepo) GetStats(ctx context.Context, q domain.StatsQuery) ([]domain.StatisticResponse, error) {
+	f.exactCalls++
+	return f.stats, nil
+}
+
+// newTestIncident - инцидент организации tenant в статусе status
+func newTestIncident(tenant uuid.UUID, status domain.IncidentStatus) *domain.Incident {
+	return &domain.Incident{
+		ID:           uuid.New(),
+		Title:        "Пожар",
+		Description:  "Горит склад",
+		Category:     domain.CategoryFire,
+		Severity:     domain.SeverityHigh,
+		Latitude:     55.75,
+		Longitude:    37.61,
+		RadiusMeters: 500,
+		Status:       status,
+		TenantID:     tenant,
+	}
+}
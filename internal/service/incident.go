@@ -7,13 +7,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"RedCollar/internal/domain"
+	"RedCollar/internal/metrics"
 	"RedCollar/internal/repository"
+	"RedCollar/internal/tracing"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // HTTPClient должен уметь делать post
@@ -33,48 +39,133 @@ type IncidentService struct {
 	rdb         repository.RedisRepository
 	warningZone float64
 	CacheTTL    int
+	//сколько хранятся HyperLogLog счётчики статистики в redis, более старые периоды считаются только по postgres
+	statsRetention time.Duration
+	//сколько хранится последняя позиция пользователя для просмотра "кто сейчас в зоне"
+	liveRetention time.Duration
+	//псевдонимы пользователей и округление координат в сохраняемых проверках, nil - сохраняем как есть
+	privacy *Privacy
 }
 
 // Принимаем объект с нужными методами(repository) и возвращаем указатель с которым будем работать
-func NewIncidentService(repo repository.IncidentRepository, rdb repository.RedisRepository, warningZone float64, CacheTTL int) *IncidentService {
-	return &IncidentService{repo: repo, rdb: rdb, warningZone: warningZone, CacheTTL: CacheTTL}
+func NewIncidentService(repo repository.IncidentRepository, rdb repository.RedisRepository, warningZone float64, CacheTTL int, statsRetentionHours, liveRetentionMinutes int, privacy *Privacy) *IncidentService {
+	return &IncidentService{
+		repo:           repo,
+		rdb:            rdb,
+		warningZone:    warningZone,
+		CacheTTL:       CacheTTL,
+		statsRetention: time.Duration(statsRetentionHours) * time.Hour,
+		liveRetention:  time.Duration(liveRetentionMinutes) * time.Minute,
+		privacy:        privacy,
+	}
+}
+
+// errInvalidID - ошибка для ID из url, который не удалось разобрать как UUID
+func errInvalidID(id string) error {
+	return domain.BadRequest(domain.CodeInvalidID, "невалидный ID: %q", id).OnField("id")
 }
 
 // ValidateCoordinates отвечает за валидацию координат и решает проблему дублирования кода
 func ValidateCoordinates(lat, lng float64) error {
 	if lat < -90 || lat > 90 {
-		return errors.New("невалидная широта (должна быть в диапазоне от -90 до 90)")
+		return domain.Invalid(domain.CodeLatitudeRange, "невалидная широта (должна быть в диапазоне от -90 до 90)").OnField("latitude")
 	}
 	if lng < -180 || lng > 180 {
-		return errors.New("невалидная долгота (должна быть в диапазоне от -180 до 180")
+		return domain.Invalid(domain.CodeLongitudeRange, "невалидная долгота (должна быть в диапазоне от -180 до 180)").OnField("longitude")
 	}
 	if lng == 0.0 || lat == 0.0 {
-		return errors.New("не указаны координаты")
+		return domain.Invalid(domain.CodeCoordinatesMissing, "не указаны координаты").OnField("latitude").OnField("longitude")
+	}
+	return nil
+}
+
+// ValidateClassification проверяет категорию и уровень опасности инцидента и проставляет дефолты, если они не указаны
+func ValidateClassification(i *domain.Incident) error {
+	if i.Category == "" {
+		i.Category = domain.CategoryOther
+	}
+	if !i.Category.IsValid() {
+		return domain.Invalid(domain.CodeUnknownCategory, "неизвестная категория инцидента: %s", i.Category).OnField("category")
+	}
+	if i.Severity == 0 {
+		i.Severity = domain.SeverityLow
+	}
+	if !i.Severity.IsValid() {
+		return domain.Invalid(domain.CodeInvalidSeverity, "невалидный уровень опасности (должен быть в диапазоне от 1 до 4)").OnField("severity")
+	}
+	return nil
+}
+
+// ValidateWindow проверяет окно действия инцидента: время завершения должно быть позже времени активации
+func ValidateWindow(i *domain.Incident) error {
+	if i.ValidFrom != nil && i.ValidUntil != nil && !i.ValidUntil.After(*i.ValidFrom) {
+		return domain.Invalid(domain.CodeInvalidSchedule, "valid_until должен быть позже valid_from").OnField("valid_until")
+	}
+	return nil
+}
+
+// ValidateFilter проверяет фильтры списка инцидентов, чтобы не ходить в базу с заведомо пустым результатом
+func ValidateFilter(f domain.IncidentFilter) error {
+	for _, c := range f.Categories {
+		if !c.IsValid() {
+			return domain.Invalid(domain.CodeUnknownCategory, "неизвестная категория инцидента: %s", c).OnField("category")
+		}
+	}
+	if f.MinSeverity != 0 && !f.MinSeverity.IsValid() {
+		return domain.Invalid(domain.CodeInvalidMinSeverity, "невалидный минимальный уровень опасности (должен быть в диапазоне от 1 до 4)").OnField("min_severity")
+	}
+	for _, st := range f.Statuses {
+		if !st.IsValid() {
+			return domain.Invalid(domain.CodeUnknownStatus, "неизвестный статус инцидента: %s", st).OnField("status")
+		}
+		if st == domain.StatusArchived {
+			return domain.Invalid(domain.CodeArchivedHidden, "архивные инциденты скрыты из списка").OnField("status")
+		}
 	}
 	return nil
 }
 
 // Create отвечает за создание инцидента, валидацию полей, установку дефолтов
 func (s *IncidentService) Create(ctx context.Context, i *domain.Incident) (string, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.Create")
+	defer span.End()
+
 	//Валидация
 	if len(i.Title) < 1 {
-		return "", errors.New("ошибка: пустой заголовок")
+		return "", domain.Invalid(domain.CodeTitleEmpty, "заголовок не может быть пустым").OnField("title")
 	}
 	if len(i.Title) > 255 {
-		return "", errors.New("заголовок слишком длинный (максимум 255 символов)")
+		return "", domain.Invalid(domain.CodeTitleTooLong, "заголовок слишком длинный (максимум 255 символов)").OnField("title")
 	}
 	err := ValidateCoordinates(i.Latitude, i.Longitude)
 	if err != nil {
 		return "", err
 	}
+	if err := ValidateClassification(i); err != nil {
+		return "", err
+	}
+	if err := ValidateWindow(i); err != nil {
+		return "", err
+	}
+	if i.ValidUntil != nil && !i.ValidUntil.After(time.Now()) {
+		return "", domain.Invalid(domain.CodeValidUntilPassed, "valid_until уже прошёл").OnField("valid_until")
+	}
 
 	//Если мы не получили радиус, или получили невалидный, то ставим валидный дефолт
 	if i.RadiusMeters <= 0 || i.RadiusMeters > 2000 {
 		i.RadiusMeters = 200
 	}
 	i.ID = uuid.New()
-	i.IsActive = true
 	i.CreatedAt = time.Now()
+	//инцидент можно создать черновиком, иначе он сразу публикуется: становится active,
+	//или scheduled, если valid_from ещё не наступил - тогда его включит планировщик
+	switch i.Status {
+	case domain.StatusDraft:
+	case "", domain.StatusActive:
+		i.Status = i.PublishedStatus(i.CreatedAt)
+	default:
+		return "", domain.Invalid(domain.CodeInitialStatus, "при создании статус инцидента может быть только draft или active").OnField("status")
+	}
 	//Когда у нас готово всё кроме i.ID, мы дёргаем метод репозитория и передаём туда всё необходимое, чтобы создать
 	//инцидент и получить uuid который мы и будем возвращать для пользователя/фронта
 	id, err := s.repo.Create(ctx, i)
@@ -87,11 +178,17 @@ func (s *IncidentService) Create(ctx context.Context, i *domain.Incident) (strin
 
 // Get отвечает за то, чтобы возвращать валидный список инцидентов в радиусе(warningZone из .env)
 // этот метод универсален и для пользователя и для оператора, а также не требует пересборки проекта ради изменения радиуса
-func (i *IncidentService) Get(ctx context.Context, lat float64, long float64, limit, offset int) ([]*domain.Incident, error) {
+func (i *IncidentService) Get(ctx context.Context, lat float64, long float64, limit, offset int, filter domain.IncidentFilter) ([]*domain.Incident, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.Get")
+	defer span.End()
+
 	err := ValidateCoordinates(lat, long)
 	if err != nil {
 		return []*domain.Incident{}, err
 	}
+	if err := ValidateFilter(filter); err != nil {
+		return []*domain.Incident{}, err
+	}
 
 	//Валидация пагинации
 	if limit <= 0 {
@@ -104,7 +201,7 @@ func (i *IncidentService) Get(ctx context.Context, lat float64, long float64, li
 		offset = 0
 	}
 	//Если всё ок - вызываем репозиторий, передаем warningZone как extraRadius
-	result, err := i.repo.Get(ctx, lat, long, limit, offset, i.warningZone)
+	result, err := i.repo.Get(ctx, lat, long, limit, offset, domain.TenantFromContext(ctx).WarningZoneOr(i.warningZone), filter)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения списка инцидентов: %w", err)
 	}
@@ -117,9 +214,12 @@ func (i *IncidentService) Get(ctx context.Context, lat float64, long float64, li
 
 // GetByID отвечает за то, чтобы вернуть какую-то конкретную запись из БД по UUID(ID)
 func (i *IncidentService) GetByID(ctx context.Context, id string) (*domain.Incident, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.GetByID")
+	defer span.End()
+
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, errors.New("невалидный ID")
+		return nil, errInvalidID(id)
 	}
 	result, err := i.repo.GetByID(ctx, parsedID)
 	if err != nil {
@@ -128,13 +228,28 @@ func (i *IncidentService) GetByID(ctx context.Context, id string) (*domain.Incid
 	return result, nil
 }
 
-// Метод Delete по условию должен деактивировать инцидент (isActive = false)
+// errAlreadyDeleted - инцидент уже завершён или в архиве, повторное удаление ничего не меняет
+var errAlreadyDeleted = errors.New("инцидент уже удалён")
+
+// Метод Delete по условию должен деактивировать инцидент: опубликованный инцидент завершается (resolved),
+// а черновик, который никто не видел, сразу уходит в архив. Повторный DELETE завершённого или архивного
+// инцидента считается успешным, чтобы клиенты могли безопасно повторять запрос
 func (i *IncidentService) Delete(ctx context.Context, id string) error {
-	parsedID, err := uuid.Parse(id)
-	if err != nil {
-		return errors.New("невалидный ID")
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.Delete")
+	defer span.End()
+
+	_, err := i.changeStatus(ctx, id, func(current *domain.Incident) (domain.IncidentStatus, error) {
+		switch current.Status {
+		case domain.StatusDraft:
+			return domain.StatusArchived, nil
+		case domain.StatusResolved, domain.StatusArchived:
+			return "", errAlreadyDeleted
+		}
+		return domain.StatusResolved, nil
+	})
+	if errors.Is(err, errAlreadyDeleted) {
+		return nil
 	}
-	err = i.repo.Delete(ctx, parsedID)
 	if err != nil {
 		return fmt.Errorf("ошибка удаления инцидента: %w", err)
 	}
@@ -143,53 +258,80 @@ func (i *IncidentService) Delete(ctx context.Context, id string) error {
 
 // Update Отвечает за обновление данных структуры по UUID
 func (i *IncidentService) Update(ctx context.Context, id string, incident *domain.Incident) (uuid.UUID, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.Update")
+	defer span.End()
+
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
-		return uuid.Nil, errors.New("невалидный ID")
+		return uuid.Nil, errInvalidID(id)
 	}
 
 	//Явно пробрасываем указанный id как id текущей сущности(структуры)
 	incident.ID = parsedID
 
 	if len(incident.Title) < 1 {
-		return uuid.Nil, errors.New("заголовок не может быть пустым")
+		return uuid.Nil, domain.Invalid(domain.CodeTitleEmpty, "заголовок не может быть пустым").OnField("title")
 	}
 	if len(incident.Title) > 255 {
-		return uuid.Nil, errors.New("заголовок слишком длинный (максимум 255 символов)")
+		return uuid.Nil, domain.Invalid(domain.CodeTitleTooLong, "заголовок слишком длинный (максимум 255 символов)").OnField("title")
 	}
 	if len(incident.Description) < 1 {
-		return uuid.Nil, errors.New("описание не может быть пустым")
+		return uuid.Nil, domain.Invalid(domain.CodeDescriptionEmpty, "описание не может быть пустым").OnField("description")
 	}
 	if len(incident.Description) > 255 {
-		return uuid.Nil, errors.New("описание слишком длинное (максимум 255 символов)")
+		return uuid.Nil, domain.Invalid(domain.CodeDescriptionTooLong, "описание слишком длинное (максимум 255 символов)").OnField("description")
 	}
 	err = ValidateCoordinates(incident.Latitude, incident.Longitude)
 	if err != nil {
 		return uuid.Nil, err
 	}
+	if err := ValidateClassification(incident); err != nil {
+		return uuid.Nil, err
+	}
+	if err := ValidateWindow(incident); err != nil {
+		return uuid.Nil, err
+	}
+	//статус меняется только через переходы, поэтому берём его из текущей записи
+	if err := i.keepStatus(ctx, incident); err != nil {
+		return uuid.Nil, err
+	}
 
 	err = i.repo.Update(ctx, incident)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("ошибка обновления инцидента: %w", err)
 	}
+	logFailure(ctx, "не удалось сбросить кэш инцидентов", i.rdb.DeleteCacheByPrefix(ctx, tenantCachePrefix(domain.TenantIDFromContext(ctx))))
 	return incident.ID, nil
 }
 
 // CheckLocation Принимает структуру запроса и отдаёт структуру ответа, которые описаны в /domain/models.go
-func (i *IncidentService) CheckLocation(ctx context.Context, request domain.LocationCheckRequest, limit, offset int) (domain.LocationCheckResponse, error) {
+func (i *IncidentService) CheckLocation(ctx context.Context, request domain.LocationCheckRequest, limit, offset int, filter domain.IncidentFilter) (domain.LocationCheckResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.CheckLocation")
+	defer span.End()
+
 	err := ValidateCoordinates(request.Latitude, request.Longitude)
 	if err != nil {
 		return domain.LocationCheckResponse{}, err
 	}
+	if err := ValidateFilter(filter); err != nil {
+		return domain.LocationCheckResponse{}, err
+	}
+	//пользователь проверяется только по действующим инцидентам, черновики и завершённые не учитываются
+	filter.Statuses = nil
+
+	//запоминаем последнюю позицию пользователя до похода в кэш, чтобы она обновлялась на каждой проверке
+	logFailure(ctx, "не удалось сохранить позицию пользователя", i.rdb.TrackPosition(ctx, request.UserID, request.Latitude, request.Longitude, time.Now()))
 
 	//создаем переменную для хранения инцидентов
 	var incidents []*domain.Incident
-	//делаем из координат запроса ключ
-	key := fmt.Sprintf("inc:%.2f:%.2f", request.Latitude, request.Longitude)
+	//делаем из координат запроса и фильтров ключ
+	key := cacheKey(ctx, request.Latitude, request.Longitude, filter)
 
 	//Проверяем есть ли по нашим координатам инцидент в кэше, чтобы не нагружать лишний раз базу
 	cacheResult, err := i.GetIncidentCache(ctx, key)
 	if err == nil && cacheResult != nil { // если нет ошибки и есть результат - отдаём результат и выходим
+		span.SetAttributes(attribute.Bool("cache.hit", true), attribute.Int("incidents.count", len(cacheResult)))
+		countCheck(len(cacheResult) > 0)
 		return domain.LocationCheckResponse{
 			IsInDanger: len(cacheResult) > 0,
 			Incidents:  cacheResult,
@@ -197,10 +339,12 @@ func (i *IncidentService) CheckLocation(ctx context.Context, request domain.Loca
 	}
 
 	//если не случился return на этапе проверки кэша - идём в базу с координатами пользователя и ищем инциденты там
-	incidents, err = i.repo.Get(ctx, request.Latitude, request.Longitude, limit, offset, i.warningZone)
+	incidents, err = i.repo.Get(ctx, request.Latitude, request.Longitude, limit, offset, domain.TenantFromContext(ctx).WarningZoneOr(i.warningZone), filter)
 	if err != nil {
+		span.RecordError(err)
 		return domain.LocationCheckResponse{}, fmt.Errorf("ошибка получения данных:%w", err)
 	}
+	span.SetAttributes(attribute.Bool("cache.hit", false), attribute.Int("incidents.count", len(incidents)))
 
 	//создаём массив с len(incidents), т.к. это более быстрое решение чем конструкция слайс+append
 	incidentIDs := make([]uuid.UUID, len(incidents))
@@ -208,7 +352,16 @@ func (i *IncidentService) CheckLocation(ctx context.Context, request domain.Loca
 	//если инциденты не пустые - кэшируем их по TTL из конфига
 	if len(incidents) > 0 {
 		ttl := time.Duration(i.CacheTTL) * time.Minute //оборачиваем переменную из конфига, прошедшую валидацию в time.Minute
-		_ = i.CacheIncidents(ctx, request.Latitude, request.Longitude, incidents, ttl)
+		//кэш не должен жить дольше, чем ближайший из инцидентов
+		now := time.Now()
+		for _, inc := range incidents {
+			if inc.ValidUntil != nil && inc.ValidUntil.Sub(now) < ttl {
+				ttl = inc.ValidUntil.Sub(now)
+			}
+		}
+		if ttl > 0 {
+			logFailure(ctx, "не удалось закэшировать инциденты", i.CacheIncidents(ctx, key, incidents, ttl))
+		}
 	}
 
 	//за один последовательный цикл мы и записываем в слайс айди всех инцидентов и вызываем метод WebhookPush()
@@ -217,44 +370,77 @@ func (i *IncidentService) CheckLocation(ctx context.Context, request domain.Loca
 		incidentIDs[inc] = incidents[inc].ID
 
 		//пушим вебхук в очередь
-		_ = i.rdb.WebhookPush(ctx, domain.Webhook{
+		logFailure(ctx, "не удалось поставить вебхук в очередь", i.rdb.WebhookPush(ctx, domain.Webhook{
+			Event:      domain.EventUserInZone,
 			UserID:     request.UserID,
 			IncidentID: incidents[inc].ID,
+			Category:   incidents[inc].Category,
+			Severity:   incidents[inc].Severity,
 			DetectedAt: time.Now(),
-		})
+		}))
 	}
 	//соответственно если инциденты найдены и выполнилась главная бизнес-логика - мы вызываем SaveCheck()
-	//и сохраняем факт проверки в БД
-	err = i.repo.SaveCheck(ctx, request.UserID, request.Latitude, request.Longitude, incidentIDs)
+	//и сохраняем факт проверки в БД, в режиме приватности - под псевдонимом и с округлёнными координатами
+	storedUserID := i.privacy.UserID(request.UserID)
+	storedLat, storedLon := i.privacy.Coordinates(request.Latitude, request.Longitude)
+	err = i.repo.SaveCheck(ctx, storedUserID, storedLat, storedLon, incidentIDs)
 	if err != nil {
 		return domain.LocationCheckResponse{}, errors.New("ошибка сохранения данных")
 	}
+	//обновляем счётчики уникальных пользователей в redis, ошибка не критична - точная статистика есть в postgres
+	logFailure(ctx, "не удалось обновить счётчики уникальных пользователей", i.rdb.TrackUniqueUsers(ctx, storedUserID, incidentIDs, time.Now(), i.statsRetention))
+	countCheck(len(incidents) > 0)
 	return domain.LocationCheckResponse{
 		IsInDanger: len(incidents) > 0,
 		Incidents:  incidents,
 	}, nil
 }
 
-// По условию задачи мы должны при запросе статистики читать переменную из .env и отдавать статистику за N минут
-// В сервис слое мы читаем переменную, обрабатываем невалидные кейсы и вызываем метод репозитория
-func (i *IncidentService) GetStats(ctx context.Context, STATS_TIME_WINDOW_MINUTES int) ([]domain.StatisticResponse, error) {
-	//timeInt = Stats_time_window_minutes из .env по условию
-	timeInt := STATS_TIME_WINDOW_MINUTES
-
-	//Вызываем сервис
-	result, err := i.repo.GetStats(ctx, timeInt)
+// logFailure логирует ошибку вспомогательной операции (кэш, очередь, счётчики), которая не должна ломать основной запрос
+func logFailure(ctx context.Context, msg string, err error) {
 	if err != nil {
-		return nil, err
+		slog.WarnContext(ctx, msg, slog.Any("error", err))
 	}
-	return result, nil
+}
+
+// countCheck учитывает результат проверки координат в метриках
+func countCheck(inDanger bool) {
+	if inDanger {
+		metrics.LocationChecks.WithLabelValues(metrics.ResultDanger).Inc()
+		return
+	}
+	metrics.LocationChecks.WithLabelValues(metrics.ResultSafe).Inc()
+}
+
+// cachePrefix - общий префикс ключей кэша проверок координат
+const cachePrefix = "inc:"
+
+// tenantCachePrefix - префикс ключей кэша одной организации, у каждой организации свои инциденты и своя зона предупреждения
+func tenantCachePrefix(tenantID uuid.UUID) string {
+	return cachePrefix + tenantID.String() + ":"
+}
+
+// cacheKey создаёт ключ в формате "inc:<tenant_id>:12.34:56.78", где 12.34 - lat, а 56.78 - lon
+// если переданы фильтры - дописываем их в ключ, чтобы отфильтрованный результат не попал в общий кэш
+func cacheKey(ctx context.Context, lat, lon float64, filter domain.IncidentFilter) string {
+	key := fmt.Sprintf("%s%.2f:%.2f", tenantCachePrefix(domain.TenantIDFromContext(ctx)), lat, lon)
+	if len(filter.Categories) > 0 {
+		categories := make([]string, len(filter.Categories))
+		for idx, c := range filter.Categories {
+			categories[idx] = string(c)
+		}
+		slices.Sort(categories)
+		key += ":c=" + strings.Join(categories, ",")
+	}
+	if filter.MinSeverity > 0 {
+		key += fmt.Sprintf(":s=%d", filter.MinSeverity)
+	}
+	return key
 }
 
 // ключ и ttl мы получаем сверху(из запроса пользователя, т.к. ключ - обрезанные координаты, а ttl мы передаем из .env)
 // внутри метода мы должны установить валидацию данных, в нашем случае координаты для ключа, и валидные параметры инцидента
-func (i *IncidentService) CacheIncidents(ctx context.Context, lat, lon float64, incidents []*domain.Incident, ttl time.Duration) error {
-	//создаём ключ в формате "inc:12:34", где 12 - lat, а 34 - lon
-	key := fmt.Sprintf("inc:%.2f:%.2f", lat, lon)
-
+func (i *IncidentService) CacheIncidents(ctx context.Context, key string, incidents []*domain.Incident, ttl time.Duration) error {
 	//полученный массив инцидентов хэшируем
 	data, err := json.Marshal(incidents)
 	if err != nil {
@@ -269,14 +455,125 @@ func (i *IncidentService) CacheIncidents(ctx context.Context, lat, lon float64,
 func (i *IncidentService) GetIncidentCache(ctx context.Context, key string) ([]*domain.Incident, error) {
 	result, err := i.rdb.GetCache(ctx, key)
 	if errors.Is(err, repository.ErrCacheMiss) { //Обрабатываем кейс когда в хранилище кэша пусто благодаря кастомной
+		metrics.CacheRequests.WithLabelValues(metrics.ResultMiss).Inc()
 		return nil, nil
 	} else if err != nil { //Обрабатываем кейс когда мы действительно получили ошибку
+		metrics.CacheRequests.WithLabelValues(metrics.ResultMiss).Inc()
 		return nil, err
 	}
 	var incidents []*domain.Incident //Создаем переменную куда будем запиысвать результат
 	err = json.Unmarshal(result, &incidents)
 	if err != nil { //Обрабатываем ошибку анмаршалинга
+		metrics.CacheRequests.WithLabelValues(metrics.ResultMiss).Inc()
 		return nil, err
 	}
+	metrics.CacheRequests.WithLabelValues(metrics.ResultHit).Inc()
+	//отбрасываем инциденты, у которых успело закончиться окно действия, пока они лежали в кэше
+	now := time.Now()
+	incidents = slices.DeleteFunc(incidents, func(inc *domain.Incident) bool {
+		return !inc.IsActiveAt(now)
+	})
 	return incidents, nil //Возвращаем слайс с полученным результатом
 }
+
+// ApplySchedule включает и выключает запланированные инциденты на границах их окна действия,
+// отправляет события жизненного цикла в очередь вебхуков и сбрасывает кэш, если что-то поменялось
+func (i *IncidentService) ApplySchedule(ctx context.Context, now time.Time) error {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.ApplySchedule")
+	defer span.End()
+
+	activated, err := i.repo.ActivateScheduled(ctx, now)
+	if err != nil {
+		return fmt.Errorf("ошибка активации запланированных инцидентов: %w", err)
+	}
+	expired, err := i.repo.ExpireScheduled(ctx, now)
+	if err != nil {
+		return fmt.Errorf("ошибка завершения инцидентов: %w", err)
+	}
+	if len(activated) == 0 && len(expired) == 0 {
+		return nil
+	}
+
+	//кэш проверок хранит результаты до смены статуса, поэтому сбрасываем его у затронутых организаций
+	tenants := make(map[uuid.UUID]struct{})
+	for _, inc := range slices.Concat(activated, expired) {
+		tenants[inc.TenantID] = struct{}{}
+	}
+	for tenantID := range tenants {
+		if err := i.rdb.DeleteCacheByPrefix(ctx, tenantCachePrefix(tenantID)); err != nil {
+			return fmt.Errorf("ошибка сброса кэша инцидентов: %w", err)
+		}
+	}
+
+	for _, inc := range activated {
+		logFailure(ctx, "не удалось поставить вебхук в очередь", i.rdb.WebhookPush(ctx, lifecycleWebhook(domain.EventIncidentActivated, inc, now)))
+	}
+	for _, inc := range expired {
+		logFailure(ctx, "не удалось поставить вебхук в очередь", i.rdb.WebhookPush(ctx, lifecycleWebhook(domain.EventIncidentExpired, inc, now)))
+	}
+	return nil
+}
+
+// lifecycleWebhook собирает вебхук о смене статуса инцидента, пользователя у таких событий нет.
+// Организацию берём из инцидента: планировщик работает сразу по всем организациям
+func lifecycleWebhook(event domain.WebhookEvent, inc *domain.Incident, now time.Time) domain.Webhook {
+	return domain.Webhook{
+		TenantID:   inc.TenantID,
+		Event:      event,
+		IncidentID: inc.ID,
+		Category:   inc.Category,
+		Severity:   inc.Severity,
+		DetectedAt: now,
+	}
+}
+
+// GetHistory отдаёт историю изменений инцидента от последней версии к первой
+func (i *IncidentService) GetHistory(ctx context.Context, id string) ([]*domain.IncidentVersion, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.GetHistory")
+	defer span.End()
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errInvalidID(id)
+	}
+	result, err := i.repo.GetHistory(ctx, parsedID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истории инцидента: %w", err)
+	}
+	return result, nil
+}
+
+// Restore возвращает инцидент в состояние указанной версии, само восстановление тоже попадает в историю
+func (i *IncidentService) Restore(ctx context.Context, id string, version int) (*domain.Incident, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.Restore")
+	defer span.End()
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errInvalidID(id)
+	}
+	v, err := i.repo.GetVersion(ctx, parsedID, version)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения версии инцидента: %w", err)
+	}
+
+	//берём состояние после изменения, т.е. то, каким инцидент был сразу после этой версии
+	incident := *v.NewValue
+	incident.ID = parsedID
+	if err := ValidateClassification(&incident); err != nil {
+		return nil, err
+	}
+	if err := ValidateWindow(&incident); err != nil {
+		return nil, err
+	}
+	//восстанавливаем только содержимое инцидента, статус остаётся текущим
+	if err := i.keepStatus(ctx, &incident); err != nil {
+		return nil, err
+	}
+
+	if err := i.repo.Restore(ctx, &incident, version); err != nil {
+		return nil, fmt.Errorf("ошибка восстановления инцидента: %w", err)
+	}
+	logFailure(ctx, "не удалось сбросить кэш инцидентов", i.rdb.DeleteCacheByPrefix(ctx, tenantCachePrefix(domain.TenantIDFromContext(ctx))))
+	return &incident, nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/tracing"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsersInZone отдаёт пользователей, последняя проверка которых была внутри радиуса инцидента не раньше maxAge назад
+func (i *IncidentService) UsersInZone(ctx context.Context, id string, maxAge time.Duration, countOnly bool) (domain.ZoneUsersResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.UsersInZone")
+	defer span.End()
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return domain.ZoneUsersResponse{}, errInvalidID(id)
+	}
+	if maxAge <= 0 || maxAge > i.liveRetention {
+		return domain.ZoneUsersResponse{}, domain.Invalid(domain.CodeInvalidMaxAge, "max_age должен быть в диапазоне от 1 секунды до %v", i.liveRetention).OnField("max_age")
+	}
+	incident, err := i.repo.GetByID(ctx, parsedID)
+	if err != nil {
+		return domain.ZoneUsersResponse{}, fmt.Errorf("ошибка получения инцидента по ID: %w", err)
+	}
+	//позиции пользователей не привязаны к инциденту, поэтому для черновика или завершённого инцидента
+	//радиус показал бы случайных прохожих, а не тех, кого касается опасность
+	if incident.Status != domain.StatusActive {
+		return domain.ZoneUsersResponse{}, domain.Conflict(domain.CodeIncidentNotActive, "инцидент не действует (статус %s), пользователей в его зоне не отслеживаем", incident.Status)
+	}
+
+	now := time.Now()
+	//заодно чистим позиции, которые старше срока хранения, чтобы GEO set не рос бесконечно
+	logFailure(ctx, "не удалось удалить устаревшие позиции", i.rdb.PrunePositions(ctx, now.Add(-i.liveRetention)))
+
+	users, err := i.rdb.UsersInRadius(ctx, incident.Latitude, incident.Longitude, incident.RadiusMeters, now.Add(-maxAge))
+	if err != nil {
+		return domain.ZoneUsersResponse{}, fmt.Errorf("ошибка получения пользователей в зоне: %w", err)
+	}
+
+	result := domain.ZoneUsersResponse{IncidentID: parsedID.String(), UserCount: len(users)}
+	if !countOnly {
+		result.Users = users
+	}
+	return result, nil
+}
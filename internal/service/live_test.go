@@ -0,0 +1,25 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUsersInZoneRequiresActiveIncident(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	tenant := uuid.New()
+	for _, status := range []domain.IncidentStatus{domain.StatusDraft, domain.StatusScheduled, domain.StatusResolved, domain.StatusArchived} {
+		incident := newTestIncident(tenant, status)
+		s := NewIncidentService(newFakeRepo(incident), rdb, 0, 60, 24, 5, nil)
+
+		_, err := s.UsersInZone(tenantCtx(tenant), incident.ID.String(), time.Minute, false)
+		var e *domain.Error
+		if !errors.As(err, &e) || e.Kind != domain.KindConflict || e.Code != domain.CodeIncidentNotActive {
+			t.Errorf("статус %s: ожидался конфликт %s, получено %v", status, domain.CodeIncidentNotActive, err)
+		}
+	}
+}
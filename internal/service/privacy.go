@@ -0,0 +1,53 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+)
+
+// pseudonymPrefix отличает псевдоним от настоящего user_id в базе, например в строках до включения режима
+const pseudonymPrefix = "p:"
+
+// Privacy - режим приватности для хранимых проверок: вместо user_id сохраняется HMAC от него, а координаты
+// округляются. Проверка координат, позиция "кто сейчас в зоне" и вебхуки работают с настоящими значениями.
+// nil - режим выключен, всё сохраняется как есть
+type Privacy struct {
+	key      []byte
+	decimals int
+}
+
+// NewPrivacy включает режим приватности, key - секрет HMAC, decimals - сколько знаков после запятой оставлять у координат
+func NewPrivacy(key string, decimals int) *Privacy {
+	return &Privacy{key: []byte(key), decimals: decimals}
+}
+
+// UserID отдаёт псевдоним пользователя. Он одинаков для одного user_id, поэтому COUNT(DISTINCT user_id)
+// в статистике считает так же, а без ключа восстановить по нему настоящий ID нельзя
+func (p *Privacy) UserID(userID string) string {
+	if p == nil {
+		return userID
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(userID))
+	return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// StoredUserIDs отдаёт все значения, под которыми пользователь может храниться в базе: строки, сохранённые до
+// включения режима, остаются с настоящим ID
+func (p *Privacy) StoredUserIDs(userID string) []string {
+	if p == nil {
+		return []string{userID}
+	}
+	return []string{userID, p.UserID(userID)}
+}
+
+// Coordinates округляет координаты до заданного количества знаков (3 знака - около 110 м)
+func (p *Privacy) Coordinates(lat, lon float64) (float64, float64) {
+	if p == nil {
+		return lat, lon
+	}
+	scale := math.Pow10(p.decimals)
+	return math.Round(lat*scale) / scale, math.Round(lon*scale) / scale
+}
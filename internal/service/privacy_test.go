@@ -0,0 +1,77 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testPrivacyKey = "0123456789abcdef0123456789abcdef"
+
+func TestPrivacyUserIDIsStableAndKeyed(t *testing.T) {
+	privacy := NewPrivacy(testPrivacyKey, 3)
+
+	pseudonym := privacy.UserID("user-42")
+	if !strings.HasPrefix(pseudonym, pseudonymPrefix) || len(pseudonym) != len(pseudonymPrefix)+64 {
+		t.Fatalf("псевдоним не в формате p:<hex HMAC-SHA256>: %q", pseudonym)
+	}
+	if strings.Contains(pseudonym, "user-42") {
+		t.Fatalf("в псевдониме виден настоящий user_id: %q", pseudonym)
+	}
+	//одинаковый псевдоним между запросами и экземплярами нужен для COUNT(DISTINCT user_id) и поиска данных пользователя
+	if again := NewPrivacy(testPrivacyKey, 3).UserID("user-42"); again != pseudonym {
+		t.Fatalf("псевдоним нестабилен: %q и %q", pseudonym, again)
+	}
+	if other := privacy.UserID("user-43"); other == pseudonym {
+		t.Fatal("разные пользователи получили один псевдоним")
+	}
+	//без ключа псевдоним не подобрать: с другим ключом получается другое значение
+	if otherKey := NewPrivacy("fedcba9876543210fedcba9876543210", 3).UserID("user-42"); otherKey == pseudonym {
+		t.Fatal("псевдоним не зависит от ключа")
+	}
+}
+
+func TestPrivacyStoredUserIDs(t *testing.T) {
+	privacy := NewPrivacy(testPrivacyKey, 3)
+
+	ids := privacy.StoredUserIDs("user-42")
+	if len(ids) != 2 || !slices.Contains(ids, "user-42") || !slices.Contains(ids, privacy.UserID("user-42")) {
+		t.Fatalf("ожидали настоящий ID и псевдоним, получили %v", ids)
+	}
+
+	var off *Privacy
+	if ids := off.StoredUserIDs("user-42"); !slices.Equal(ids, []string{"user-42"}) {
+		t.Fatalf("без режима приватности ожидали только настоящий ID, получили %v", ids)
+	}
+	if id := off.UserID("user-42"); id != "user-42" {
+		t.Fatalf("без режима приватности user_id изменён: %q", id)
+	}
+}
+
+func TestPrivacyCoordinates(t *testing.T) {
+	cases := []struct {
+		name             string
+		decimals         int
+		lat, lon         float64
+		wantLat, wantLon float64
+	}{
+		{"3 знака", 3, 55.751234, 37.617891, 55.751, 37.618},
+		{"южное и западное полушарие", 3, -33.868512, -151.209349, -33.869, -151.209},
+		{"2 знака", 2, 55.756, 37.614, 55.76, 37.61},
+		{"0 знаков", 0, 55.5, 37.49, 56, 37},
+		{"уже округлены", 3, 55.75, 37.6, 55.75, 37.6},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lat, lon := NewPrivacy(testPrivacyKey, tc.decimals).Coordinates(tc.lat, tc.lon)
+			if lat != tc.wantLat || lon != tc.wantLon {
+				t.Fatalf("получили (%v, %v), ожидали (%v, %v)", lat, lon, tc.wantLat, tc.wantLon)
+			}
+		})
+	}
+
+	var off *Privacy
+	if lat, lon := off.Coordinates(55.751234, 37.617891); lat != 55.751234 || lon != 37.617891 {
+		t.Fatalf("без режима приватности координаты изменены: (%v, %v)", lat, lon)
+	}
+}
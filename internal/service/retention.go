@@ -0,0 +1,125 @@
+package service
+
+import (
+	"RedCollar/internal/metrics"
+	"RedCollar/internal/repository"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// partitionsAhead - на сколько дней вперёд держим готовые секции location_checks
+const partitionsAhead = 7
+
+// RetentionService ограничивает срок хранения точных координат пользователей: проверки старше срока
+// сворачиваются в почасовые итоги по инцидентам и ячейкам тепловой карты, а сами строки удаляются
+type RetentionService struct {
+	repo      repository.RetentionRepository
+	retention time.Duration
+	batch     int
+}
+
+// retentionDays - сколько дней хранить сырые проверки, batch - сколько строк удалять за один запрос
+func NewRetentionService(repo repository.RetentionRepository, retentionDays, batch int) *RetentionService {
+	return &RetentionService{
+		repo:      repo,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		batch:     batch,
+	}
+}
+
+// Prune сворачивает и удаляет проверки старше срока хранения. Если таблица секционирована,
+// целиком устаревшие секции удаляются без DELETE, а остаток добирается пачками
+func (s *RetentionService) Prune(ctx context.Context, now time.Time) error {
+	//без срока хранения проверки хранятся бессрочно
+	if s.retention <= 0 {
+		return nil
+	}
+	//граница по целому часу: свёрнутый час больше не пересчитывается, поэтому удаляем только целиком свёрнутые часы
+	before := now.Add(-s.retention).Truncate(time.Hour)
+
+	//итоги тепловой карты храним по самой мелкой сетке, из неё собирается карта любой точности
+	cellLat, cellLon := geohashCellSize(maxHeatmapPrecision)
+	rolled, err := s.repo.RollupChecks(ctx, before, cellLat, cellLon)
+	if err != nil {
+		return err
+	}
+
+	partitioned, err := s.repo.ChecksPartitioned(ctx)
+	if err != nil {
+		return err
+	}
+	if partitioned {
+		dropped, err := s.repo.DropCheckPartitions(ctx, before)
+		if len(dropped) > 0 {
+			slog.InfoContext(ctx, "удалены устаревшие секции location_checks", slog.Any("partitions", dropped))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var deleted int64
+	for {
+		n, err := s.repo.DeleteChecks(ctx, before, s.batch)
+		if err != nil {
+			return err
+		}
+		deleted += n
+		metrics.LocationChecksPruned.Add(float64(n))
+		if n < int64(s.batch) {
+			break
+		}
+		//между пачками проверяем отмену, чтобы остановка сервиса не ждала удаления всего хвоста
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("удаление старых проверок прервано: %w", err)
+		}
+	}
+
+	//записи о доставке вебхуков содержат ID пользователей и хранятся столько же, сколько проверки
+	var deliveries int64
+	for {
+		n, err := s.repo.DeleteDeliveries(ctx, before, s.batch)
+		if err != nil {
+			return err
+		}
+		deliveries += n
+		if n < int64(s.batch) {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("удаление старых доставок вебхуков прервано: %w", err)
+		}
+	}
+	slog.InfoContext(ctx, "срок хранения проверок применён", slog.Time("before", before),
+		slog.Int64("rolled_up_rows", rolled), slog.Int64("deleted", deleted), slog.Int64("deleted_deliveries", deliveries))
+	return nil
+}
+
+// EnsurePartitions создаёт секции location_checks на partitionsAhead дней вперёд, если таблица секционирована.
+// Не зависит от срока хранения: без секций новые проверки копятся в секции по умолчанию
+func (s *RetentionService) EnsurePartitions(ctx context.Context, now time.Time) error {
+	partitioned, err := s.repo.ChecksPartitioned(ctx)
+	if err != nil || !partitioned {
+		return err
+	}
+	return s.repo.EnsureCheckPartitions(ctx, now, partitionsAhead)
+}
+
+// Partition секционирует location_checks по дням, после этого устаревшие проверки удаляются целыми секциями
+func (s *RetentionService) Partition(ctx context.Context, now time.Time) error {
+	partitioned, err := s.repo.ChecksPartitioned(ctx)
+	if err != nil {
+		return err
+	}
+	if partitioned {
+		return fmt.Errorf("location_checks уже секционирована")
+	}
+	//сегодняшние строки остаются в старой таблице, дневные секции начинаются с завтрашнего дня
+	boundary := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if err := s.repo.PartitionChecks(ctx, boundary); err != nil {
+		return err
+	}
+	return s.repo.EnsureCheckPartitions(ctx, boundary, partitionsAhead)
+}
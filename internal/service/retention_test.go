@@ -0,0 +1,60 @@
+package service
+
+import (
+	"RedCollar/internal/repository"
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeRetention - секционированная location_checks без строк, запоминает созданные секции и удаления
+type fakeRetention struct {
+	repository.RetentionRepository
+	partitioned bool
+	ensured     []time.Time
+	rolled      int
+}
+
+func (f *fakeRetention) ChecksPartitioned(ctx context.Context) (bool, error) {
+	return f.partitioned, nil
+}
+
+func (f *fakeRetention) EnsureCheckPartitions(ctx context.Context, from time.Time, days int) error {
+	f.ensured = append(f.ensured, from)
+	return nil
+}
+
+func (f *fakeRetention) RollupChecks(ctx context.Context, before time.Time, cellLat, cellLon float64) (int64, error) {
+	f.rolled++
+	return 0, nil
+}
+
+func TestEnsurePartitionsWithoutRetention(t *testing.T) {
+	repo := &fakeRetention{partitioned: true}
+	s := NewRetentionService(repo, 0, 100)
+	now := time.Now()
+
+	if err := s.EnsurePartitions(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.ensured) != 1 || !repo.ensured[0].Equal(now) {
+		t.Fatalf("секции не созданы без срока хранения: %v", repo.ensured)
+	}
+	//без срока хранения ничего не сворачивается и не удаляется
+	if err := s.Prune(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	if repo.rolled != 0 {
+		t.Fatal("Prune без срока хранения свернул проверки")
+	}
+}
+
+func TestEnsurePartitionsSkipsPlainTable(t *testing.T) {
+	repo := &fakeRetention{}
+	if err := NewRetentionService(repo, 30, 100).EnsurePartitions(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.ensured) != 0 {
+		t.Fatal("секции создаются у несекционированной таблицы")
+	}
+}
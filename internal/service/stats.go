@@ -0,0 +1,214 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"RedCollar/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxStatsBuckets ограничивает длину ряда, чтобы разбивка по минутам за год не положила сервис
+const maxStatsBuckets = 1440
+
+// errNoStatsCounters - в индексе счётчиков redis нет инцидентов за период, например после перезапуска redis
+// без сохранения данных, хотя в postgres проверки есть
+var errNoStatsCounters = errors.New("в redis нет счётчиков статистики за период")
+
+// По условию задачи мы должны при запросе статистики читать переменную из .env и отдавать статистику за N минут
+// Если оператор не указал период - берём последние STATS_TIME_WINDOW_MINUTES минут, если указал bucket - добавляем разбивку
+func (i *IncidentService) GetStats(ctx context.Context, STATS_TIME_WINDOW_MINUTES int, q domain.StatsQuery) ([]domain.StatisticResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.GetStats")
+	defer span.End()
+
+	//у организации может быть своё окно статистики по умолчанию
+	STATS_TIME_WINDOW_MINUTES = domain.TenantFromContext(ctx).StatsWindowOr(STATS_TIME_WINDOW_MINUTES)
+
+	q, err := normalizeStatsQuery(q, STATS_TIME_WINDOW_MINUTES)
+	if err != nil {
+		return nil, err
+	}
+
+	//по умолчанию считаем по счётчикам в redis, но они хранятся ограниченное время,
+	//поэтому более старые периоды автоматически считаем точно по postgres.
+	//Если redis недоступен, счётчиков нет или период слишком длинный, статистика тоже считается точно, только медленнее
+	if q.Mode != domain.StatsExact && q.From.After(time.Now().Add(-i.statsRetention)) {
+		result, err := i.approxStats(ctx, q)
+		switch {
+		case err == nil:
+			return result, nil
+		case errors.Is(err, errNoStatsCounters), errors.Is(err, repository.ErrTooManyStatsKeys):
+			slog.DebugContext(ctx, "статистика считается по postgres", slog.Any("reason", err))
+		default:
+			logFailure(ctx, "не удалось посчитать статистику по счётчикам redis, считаем по postgres", err)
+		}
+	}
+
+	//Вызываем репозиторий
+	result, err := i.repo.GetStats(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if q.Bucket == "" {
+		return result, nil
+	}
+
+	series, err := i.repo.GetStatsSeries(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range result {
+		result[idx].Series = fillBuckets(q, series[result[idx].IncidentID])
+	}
+	return result, nil
+}
+
+// GetIncidentStats отдаёт статистику по одному инциденту, если проверок за период не было - нулевую
+func (i *IncidentService) GetIncidentStats(ctx context.Context, id string, STATS_TIME_WINDOW_MINUTES int, q domain.StatsQuery) (domain.StatisticResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.GetIncidentStats")
+	defer span.End()
+
+	//у организации может быть своё окно статистики по умолчанию
+	STATS_TIME_WINDOW_MINUTES = domain.TenantFromContext(ctx).StatsWindowOr(STATS_TIME_WINDOW_MINUTES)
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return domain.StatisticResponse{}, errInvalidID(id)
+	}
+	//убеждаемся, что инцидент существует, иначе ответ с нулями вводил бы в заблуждение
+	if _, err := i.repo.GetByID(ctx, parsedID); err != nil {
+		return domain.StatisticResponse{}, fmt.Errorf("ошибка получения инцидента по ID: %w", err)
+	}
+
+	q.IncidentID = &parsedID
+	result, err := i.GetStats(ctx, STATS_TIME_WINDOW_MINUTES, q)
+	if err != nil {
+		return domain.StatisticResponse{}, err
+	}
+	if len(result) > 0 {
+		return result[0], nil
+	}
+
+	empty := domain.StatisticResponse{IncidentID: parsedID.String()}
+	if q.Bucket != "" {
+		q, _ = normalizeStatsQuery(q, STATS_TIME_WINDOW_MINUTES)
+		empty.Series = fillBuckets(q, nil)
+	}
+	return empty, nil
+}
+
+// approxStats считает статистику по HyperLogLog счётчикам в redis, не обращаясь к location_checks.
+// Все счётчики читаются двумя пайплайнами: итоги по инцидентам, затем ряды тех, у кого были проверки
+func (i *IncidentService) approxStats(ctx context.Context, q domain.StatsQuery) ([]domain.StatisticResponse, error) {
+	ids, err := i.rdb.StatsIncidents(ctx, q.From)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения списка инцидентов для статистики: %w", err)
+	}
+	//архивные инциденты убираются из индекса, поэтому и статистика одного инцидента берётся только по индексу
+	if q.IncidentID != nil {
+		ids = slices.DeleteFunc(ids, func(id string) bool { return id != q.IncidentID.String() })
+	}
+	//пустой индекс не значит, что проверок не было: redis мог потерять данные, а postgres их хранит
+	if len(ids) == 0 {
+		return nil, errNoStatsCounters
+	}
+
+	totals := make([]domain.UniqueUsersRange, len(ids))
+	for idx, id := range ids {
+		totals[idx] = domain.UniqueUsersRange{IncidentID: id, From: q.From, To: q.To}
+	}
+	counts, err := i.rdb.CountUniqueUsers(ctx, totals)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]domain.StatisticResponse, 0, len(ids))
+	for idx, id := range ids {
+		//как и в postgres, инциденты без проверок за период в ответ не попадают
+		if counts[idx] == 0 {
+			continue
+		}
+		result = append(result, domain.StatisticResponse{IncidentID: id, UserCount: counts[idx], Approximate: true})
+	}
+	if q.Bucket == "" || len(result) == 0 {
+		return result, nil
+	}
+
+	var buckets []domain.UniqueUsersRange
+	for idx := range result {
+		result[idx].Series = fillBuckets(q, nil)
+		for _, point := range result[idx].Series {
+			from := point.BucketStart
+			to := from.Add(q.Bucket.Duration())
+			//крайние интервалы обрезаем по границам запрошенного периода
+			if from.Before(q.From) {
+				from = q.From
+			}
+			if to.After(q.To) {
+				to = q.To
+			}
+			buckets = append(buckets, domain.UniqueUsersRange{IncidentID: result[idx].IncidentID, From: from, To: to})
+		}
+	}
+	if counts, err = i.rdb.CountUniqueUsers(ctx, buckets); err != nil {
+		return nil, err
+	}
+	n := 0
+	for idx := range result {
+		for p := range result[idx].Series {
+			result[idx].Series[p].UserCount = counts[n]
+			n++
+		}
+	}
+	return result, nil
+}
+
+// normalizeStatsQuery проставляет дефолтный период и проверяет параметры запроса статистики
+func normalizeStatsQuery(q domain.StatsQuery, defaultMinutes int) (domain.StatsQuery, error) {
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-time.Duration(defaultMinutes) * time.Minute)
+	}
+	if !q.To.After(q.From) {
+		return q, domain.Invalid(domain.CodeInvalidPeriod, "конец периода должен быть позже начала").OnField("to")
+	}
+	if q.Mode != "" && q.Mode != domain.StatsApprox && q.Mode != domain.StatsExact {
+		return q, domain.Invalid(domain.CodeUnknownStatsMode, "неизвестный режим статистики: %s (допустимо approx, exact)", q.Mode).OnField("mode")
+	}
+	if q.Bucket == "" {
+		return q, nil
+	}
+
+	size := q.Bucket.Duration()
+	if size == 0 {
+		return q, domain.Invalid(domain.CodeUnknownBucket, "неизвестный размер интервала: %s (допустимо minute, hour, day)", q.Bucket).OnField("bucket")
+	}
+	if buckets := q.To.Sub(q.From.Truncate(size)) / size; buckets > maxStatsBuckets {
+		return q, domain.Invalid(domain.CodeTooManyBuckets, "слишком много интервалов (%d), максимум %d - увеличьте bucket или сократите период", buckets, maxStatsBuckets).OnField("bucket")
+	}
+	return q, nil
+}
+
+// fillBuckets достраивает ряд до непрерывного: интервалы без проверок получают user_count = 0
+func fillBuckets(q domain.StatsQuery, points []domain.StatsPoint) []domain.StatsPoint {
+	size := q.Bucket.Duration()
+	counts := make(map[time.Time]int, len(points))
+	for _, p := range points {
+		counts[p.BucketStart] = p.UserCount
+	}
+
+	//time.Truncate выравнивает по UTC, так же как date_trunc(..., 'UTC') в запросе
+	series := make([]domain.StatsPoint, 0)
+	for start := q.From.UTC().Truncate(size); start.Before(q.To); start = start.Add(size) {
+		series = append(series, domain.StatsPoint{BucketStart: start, UserCount: counts[start]})
+	}
+	return series
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestApproxStatsWithSeries(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	tenant := uuid.New()
+	ctx := tenantCtx(tenant)
+	first, second := newTestIncident(tenant, domain.StatusActive), newTestIncident(tenant, domain.StatusActive)
+	repo := newFakeRepo(first, second)
+	s := NewIncidentService(repo, rdb, 0, 60, 24, 5, nil)
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	track := func(user string, at time.Time, ids ...uuid.UUID) {
+		if err := rdb.TrackUniqueUsers(ctx, user, ids, at, time.Hour); err != nil {
+			t.Fatalf("TrackUniqueUsers: %v", err)
+		}
+	}
+	track("u1", now.Add(-3*time.Minute), first.ID, second.ID)
+	track("u2", now.Add(-3*time.Minute), first.ID)
+	track("u1", now.Add(-time.Minute), first.ID)
+
+	q := domain.StatsQuery{From: now.Add(-5 * time.Minute), To: now, Bucket: domain.BucketMinute}
+	result, err := s.GetStats(ctx, 60, q)
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if repo.exactCalls != 0 {
+		t.Fatalf("статистика посчитана по postgres, ожидались счётчики redis")
+	}
+	got := make(map[string]domain.StatisticResponse)
+	for _, r := range result {
+		got[r.IncidentID] = r
+	}
+	if got[first.ID.String()].UserCount != 2 || got[second.ID.String()].UserCount != 1 {
+		t.Fatalf("неверные итоги: %+v", result)
+	}
+	series := got[first.ID.String()].Series
+	if len(series) != 5 || series[2].UserCount != 2 || series[4].UserCount != 1 || series[0].UserCount != 0 {
+		t.Fatalf("неверный ряд: %+v", series)
+	}
+}
+
+func TestApproxIncidentStatsSkipsArchived(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	tenant := uuid.New()
+	ctx := tenantCtx(tenant)
+	archived := newTestIncident(tenant, domain.StatusArchived)
+	s := NewIncidentService(newFakeRepo(archived), rdb, 0, 60, 24, 5, nil)
+
+	now := time.Now()
+	if err := rdb.TrackUniqueUsers(ctx, "u1", []uuid.UUID{archived.ID}, now.Add(-time.Minute), time.Hour); err != nil {
+		t.Fatalf("TrackUniqueUsers: %v", err)
+	}
+	//при переходе в archived инцидент убирается из индекса счётчиков
+	if err := rdb.ForgetStatsIncident(ctx, archived.ID.String()); err != nil {
+		t.Fatalf("ForgetStatsIncident: %v", err)
+	}
+
+	stat, err := s.GetIncidentStats(ctx, archived.ID.String(), 10, domain.StatsQuery{})
+	if err != nil {
+		t.Fatalf("GetIncidentStats: %v", err)
+	}
+	if stat.UserCount != 0 {
+		t.Fatalf("архивный инцидент попал в статистику: %+v", stat)
+	}
+}
+
+func TestStatsFallBackToPostgresWhenRedisFails(t *testing.T) {
+	m, rdb := newTestRedis(t)
+	tenant := uuid.New()
+	incident := newTestIncident(tenant, domain.StatusActive)
+	repo := newFakeRepo(incident)
+	repo.stats = []domain.StatisticResponse{{IncidentID: incident.ID.String(), UserCount: 7}}
+	s := NewIncidentService(repo, rdb, 0, 60, 24, 5, nil)
+	m.Close()
+
+	result, err := s.GetStats(tenantCtx(tenant), 10, domain.StatsQuery{})
+	if err != nil {
+		t.Fatalf("GetStats при недоступном redis: %v", err)
+	}
+	if repo.exactCalls != 1 || len(result) != 1 || result[0].UserCount != 7 || result[0].Approximate {
+		t.Fatalf("ожидалась точная статистика из postgres, получено %+v", result)
+	}
+}
+
+func TestStatsFallBackToPostgresWhenCountersAreMissing(t *testing.T) {
+	//пустой redis, как после перезапуска без сохранения данных
+	_, rdb := newTestRedis(t)
+	tenant := uuid.New()
+	incident := newTestIncident(tenant, domain.StatusActive)
+	repo := newFakeRepo(incident)
+	repo.stats = []domain.StatisticResponse{{IncidentID: incident.ID.String(), UserCount: 7}}
+	s := NewIncidentService(repo, rdb, 0, 60, 24, 5, nil)
+
+	result, err := s.GetStats(tenantCtx(tenant), 10, domain.StatsQuery{})
+	if err != nil {
+		t.Fatalf("GetStats без счётчиков: %v", err)
+	}
+	if repo.exactCalls != 1 || len(result) != 1 || result[0].UserCount != 7 {
+		t.Fatalf("ожидалась статистика из postgres, получено %+v", result)
+	}
+}
+
+func TestStatsFallBackToPostgresForLongPeriods(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	tenant := uuid.New()
+	ctx := tenantCtx(tenant)
+	repo := newFakeRepo()
+	s := NewIncidentService(repo, rdb, 0, 60, 24, 5, nil)
+
+	now := time.Now()
+	//итоги за 23 часа по 8 инцидентам - больше 10000 поминутных счётчиков за один подсчёт
+	for range 8 {
+		if err := rdb.TrackUniqueUsers(ctx, "u1", []uuid.UUID{uuid.New()}, now.Add(-time.Minute), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := domain.StatsQuery{From: now.Add(-23 * time.Hour), To: now}
+	if _, err := s.GetStats(ctx, 10, q); err != nil {
+		t.Fatalf("GetStats за длинный период: %v", err)
+	}
+	if repo.exactCalls != 1 {
+		t.Fatal("длинный период посчитан по счётчикам redis")
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/tracing"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Publish публикует черновик или повторно запланированный инцидент: он становится active,
+// либо scheduled, если valid_from ещё не наступил
+func (i *IncidentService) Publish(ctx context.Context, id string) (*domain.Incident, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.Publish")
+	defer span.End()
+
+	return i.changeStatus(ctx, id, func(current *domain.Incident) (domain.IncidentStatus, error) {
+		to := current.PublishedStatus(time.Now())
+		//завершённый инцидент возвращается в работу только через Reopen, который проверяет окно действия
+		if current.Status != domain.StatusDraft && current.Status != domain.StatusScheduled {
+			return "", domain.Conflict(domain.CodeStatusTransition, "переход инцидента из статуса %s в статус %s запрещён", current.Status, to)
+		}
+		return to, nil
+	})
+}
+
+// Resolve завершает инцидент: он перестаёт участвовать в проверках, но остаётся в истории и статистике
+func (i *IncidentService) Resolve(ctx context.Context, id string) (*domain.Incident, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.Resolve")
+	defer span.End()
+
+	return i.changeStatus(ctx, id, func(current *domain.Incident) (domain.IncidentStatus, error) {
+		return domain.StatusResolved, nil
+	})
+}
+
+// Reopen возвращает завершённый инцидент в работу, если его окно действия ещё не закончилось
+func (i *IncidentService) Reopen(ctx context.Context, id string) (*domain.Incident, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.Reopen")
+	defer span.End()
+
+	return i.changeStatus(ctx, id, func(current *domain.Incident) (domain.IncidentStatus, error) {
+		if current.Status != domain.StatusResolved {
+			return "", domain.Conflict(domain.CodeReopenNotResolved, "переоткрыть можно только завершённый инцидент, текущий статус: %s", current.Status)
+		}
+		if current.ValidUntil != nil && !current.ValidUntil.After(time.Now()) {
+			return "", domain.Conflict(domain.CodeScheduleExpired, "окно действия инцидента закончилось, сначала измените valid_until")
+		}
+		return current.PublishedStatus(time.Now()), nil
+	})
+}
+
+// Archive убирает инцидент в архив, после этого он скрыт из списков и статистики и больше не меняется
+func (i *IncidentService) Archive(ctx context.Context, id string) (*domain.Incident, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "IncidentService.Archive")
+	defer span.End()
+
+	return i.changeStatus(ctx, id, func(current *domain.Incident) (domain.IncidentStatus, error) {
+		return domain.StatusArchived, nil
+	})
+}
+
+// changeStatus - общая логика переходов: читаем текущий статус, спрашиваем у next целевой статус,
+// проверяем, что переход разрешён, и записываем его
+func (i *IncidentService) changeStatus(ctx context.Context, id string, next func(current *domain.Incident) (domain.IncidentStatus, error)) (*domain.Incident, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errInvalidID(id)
+	}
+	current, err := i.repo.GetByID(ctx, parsedID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения инцидента по ID: %w", err)
+	}
+
+	to, err := next(current)
+	if err != nil {
+		return nil, err
+	}
+	if !current.Status.CanTransitionTo(to) {
+		return nil, domain.Conflict(domain.CodeStatusTransition, "переход инцидента из статуса %s в статус %s запрещён", current.Status, to)
+	}
+
+	updated, err := i.repo.SetStatus(ctx, parsedID, current.Status, to)
+	if err != nil {
+		return nil, err
+	}
+
+	//результаты проверок в кэше посчитаны для старого статуса
+	logFailure(ctx, "не удалось сбросить кэш инцидентов", i.rdb.DeleteCacheByPrefix(ctx, tenantCachePrefix(domain.TenantIDFromContext(ctx))))
+	//архивные инциденты скрыты из статистики, поэтому убираем их из индекса счётчиков
+	if to == domain.StatusArchived {
+		logFailure(ctx, "не удалось удалить счётчики статистики инцидента", i.rdb.ForgetStatsIncident(ctx, updated.ID.String()))
+	}
+
+	switch to {
+	case domain.StatusActive:
+		logFailure(ctx, "не удалось поставить вебхук в очередь", i.rdb.WebhookPush(ctx, lifecycleWebhook(domain.EventIncidentActivated, updated, time.Now())))
+	case domain.StatusResolved:
+		logFailure(ctx, "не удалось поставить вебхук в очередь", i.rdb.WebhookPush(ctx, lifecycleWebhook(domain.EventIncidentResolved, updated, time.Now())))
+	}
+	return updated, nil
+}
+
+// keepStatus переносит в изменённый инцидент статус текущей записи: при изменении содержимого статус не меняется,
+// кроме опубликованных инцидентов, которые переключаются между scheduled и active по новому окну действия
+func (i *IncidentService) keepStatus(ctx context.Context, incident *domain.Incident) error {
+	current, err := i.repo.GetByID(ctx, incident.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения инцидента по ID: %w", err)
+	}
+	switch current.Status {
+	case domain.StatusArchived:
+		return domain.Conflict(domain.CodeIncidentArchived, "архивный инцидент нельзя изменить")
+	case domain.StatusScheduled, domain.StatusActive:
+		incident.Status = incident.PublishedStatus(time.Now())
+	default:
+		incident.Status = current.Status
+	}
+	return nil
+}
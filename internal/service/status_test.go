@@ -0,0 +1,72 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishFromDraft(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	tenant := uuid.New()
+	draft := newTestIncident(tenant, domain.StatusDraft)
+	s := NewIncidentService(newFakeRepo(draft), rdb, 0, 60, 24, 5, nil)
+
+	got, err := s.Publish(tenantCtx(tenant), draft.ID.String())
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if got.Status != domain.StatusActive {
+		t.Fatalf("статус %s, ожидался active", got.Status)
+	}
+}
+
+func TestPublishRejectsResolved(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	tenant := uuid.New()
+	resolved := newTestIncident(tenant, domain.StatusResolved)
+	repo := newFakeRepo(resolved)
+	s := NewIncidentService(repo, rdb, 0, 60, 24, 5, nil)
+
+	_, err := s.Publish(tenantCtx(tenant), resolved.ID.String())
+	var e *domain.Error
+	if !errors.As(err, &e) || e.Kind != domain.KindConflict || e.Code != domain.CodeStatusTransition {
+		t.Fatalf("ожидался конфликт %s, получено %v", domain.CodeStatusTransition, err)
+	}
+	if repo.incidents[resolved.ID].Status != domain.StatusResolved {
+		t.Fatalf("статус изменился на %s", repo.incidents[resolved.ID].Status)
+	}
+}
+
+func TestDeleteIsIdempotent(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	tenant := uuid.New()
+	ctx := tenantCtx(tenant)
+	active := newTestIncident(tenant, domain.StatusActive)
+	archived := newTestIncident(tenant, domain.StatusArchived)
+	repo := newFakeRepo(active, archived)
+	s := NewIncidentService(repo, rdb, 0, 60, 24, 5, nil)
+
+	for attempt := range 2 {
+		if err := s.Delete(ctx, active.ID.String()); err != nil {
+			t.Fatalf("DELETE №%d: %v", attempt+1, err)
+		}
+	}
+	if repo.incidents[active.ID].Status != domain.StatusResolved || repo.statusSets != 1 {
+		t.Fatalf("статус %s после %d переходов, ожидался один переход в resolved", repo.incidents[active.ID].Status, repo.statusSets)
+	}
+
+	if err := s.Delete(ctx, archived.ID.String()); err != nil {
+		t.Fatalf("DELETE архивного инцидента: %v", err)
+	}
+	if repo.incidents[archived.ID].Status != domain.StatusArchived || repo.statusSets != 1 {
+		t.Fatalf("архивный инцидент изменён: %s", repo.incidents[archived.ID].Status)
+	}
+
+	//несуществующий инцидент по-прежнему 404
+	if err := s.Delete(ctx, uuid.NewString()); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DELETE несуществующего инцидента: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tenantCacheTTL - сколько организация живёт в памяти. Организация нужна на каждый запрос,
+// а меняется редко, поэтому изменения настроек на других репликах применяются с такой задержкой
+const tenantCacheTTL = time.Minute
+
+type cachedTenant struct {
+	tenant   *domain.Tenant
+	loadedAt time.Time
+}
+
+// TenantService управляет организациями и отдаёт их по ID и slug с кэшем в памяти
+type TenantService struct {
+	repo repository.TenantRepository
+
+	mu     sync.RWMutex
+	byID   map[uuid.UUID]cachedTenant
+	bySlug map[string]cachedTenant
+}
+
+func NewTenantService(repo repository.TenantRepository) *TenantService {
+	return &TenantService{
+		repo:   repo,
+		byID:   make(map[uuid.UUID]cachedTenant),
+		bySlug: make(map[string]cachedTenant),
+	}
+}
+
+// Get отдаёт организацию по ID, domain.ErrTenantNotFound - организации нет
+func (s *TenantService) Get(ctx context.Context, id uuid.UUID) (*domain.Tenant, error) {
+	s.mu.RLock()
+	cached, ok := s.byID[id]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < tenantCacheTTL {
+		return cached.tenant, nil
+	}
+	tenant, err := s.repo.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.remember(tenant)
+	return tenant, nil
+}
+
+// GetBySlug отдаёт организацию по slug из заголовка X-Tenant или claim tenant в JWT
+func (s *TenantService) GetBySlug(ctx context.Context, slug string) (*domain.Tenant, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	s.mu.RLock()
+	cached, ok := s.bySlug[slug]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < tenantCacheTTL {
+		return cached.tenant, nil
+	}
+	tenant, err := s.repo.GetTenantBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	s.remember(tenant)
+	return tenant, nil
+}
+
+func (s *TenantService) remember(tenant *domain.Tenant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	//после смены slug старая запись не должна находиться по прежнему slug
+	if old, ok := s.byID[tenant.ID]; ok && old.tenant.Slug != tenant.Slug {
+		delete(s.bySlug, old.tenant.Slug)
+	}
+	cached := cachedTenant{tenant: tenant, loadedAt: time.Now()}
+	s.byID[tenant.ID] = cached
+	s.bySlug[tenant.Slug] = cached
+}
+
+// Create заводит новую организацию
+func (s *TenantService) Create(ctx context.Context, req domain.TenantRequest) (*domain.Tenant, error) {
+	req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	req.Name = strings.TrimSpace(req.Name)
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	tenant, err := s.repo.CreateTenant(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	s.remember(tenant)
+	return tenant, nil
+}
+
+func (s *TenantService) List(ctx context.Context) ([]*domain.Tenant, error) {
+	return s.repo.ListTenants(ctx)
+}
+
+// Update меняет slug, название и настройки организации, на этой реплике изменения применяются сразу
+func (s *TenantService) Update(ctx context.Context, id string, req domain.TenantRequest) (*domain.Tenant, error) {
+	tenantID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errInvalidID(id)
+	}
+	req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	req.Name = strings.TrimSpace(req.Name)
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	tenant, err := s.repo.UpdateTenant(ctx, tenantID, req)
+	if err != nil {
+		return nil, err
+	}
+	s.remember(tenant)
+	return tenant, nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxUserIDLen - user_id хранится в VARCHAR(255)
+const maxUserIDLen = 255
+
+// UserDataService выгружает и удаляет данные пользователя по его запросу и сохраняет доставки вебхуков
+type UserDataService struct {
+	repo    repository.UserDataRepository
+	rdb     repository.RedisRepository
+	privacy *Privacy //в режиме приватности пользователь хранится в postgres под псевдонимом
+}
+
+func NewUserDataService(repo repository.UserDataRepository, rdb repository.RedisRepository, privacy *Privacy) *UserDataService {
+	return &UserDataService{repo: repo, rdb: rdb, privacy: privacy}
+}
+
+// SaveDelivery сохраняет итог доставки вебхука. Получатель видит настоящий user_id, а в базе он хранится
+// так же, как в проверках координат
+func (s *UserDataService) SaveDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	if d.UserID != "" {
+		d.UserID = s.privacy.UserID(d.UserID)
+	}
+	return s.repo.SaveDelivery(ctx, d)
+}
+
+func validateUserID(userID string) (string, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" || len(userID) > maxUserIDLen {
+		return "", domain.BadRequest(domain.CodeInvalidUserID, "некорректный user_id").OnField("user_id")
+	}
+	return userID, nil
+}
+
+// Export собирает все проверки координат и доставки вебхуков пользователя в организации запроса
+func (s *UserDataService) Export(ctx context.Context, userID string) (domain.UserDataExport, error) {
+	userID, err := validateUserID(userID)
+	if err != nil {
+		return domain.UserDataExport{}, err
+	}
+	checks, err := s.repo.UserChecks(ctx, s.privacy.StoredUserIDs(userID))
+	if err != nil {
+		return domain.UserDataExport{}, err
+	}
+	deliveries, err := s.repo.UserDeliveries(ctx, s.privacy.StoredUserIDs(userID))
+	if err != nil {
+		return domain.UserDataExport{}, err
+	}
+	return domain.UserDataExport{
+		UserID:     userID,
+		ExportedAt: time.Now().UTC(),
+		Checks:     checks,
+		Deliveries: deliveries,
+	}, nil
+}
+
+// Erase удаляет данные пользователя в организации запроса (и под настоящим ID, и под псевдонимом): сначала состояние в Redis, чтобы новые вебхуки
+// о нём не ушли после удаления, затем проверки и доставки в PostgreSQL.
+// Поминутные HyperLogLog счётчики статистики не хранят ID в извлекаемом виде и истекают сами
+func (s *UserDataService) Erase(ctx context.Context, userID string) (domain.UserErasure, error) {
+	userID, err := validateUserID(userID)
+	if err != nil {
+		return domain.UserErasure{}, err
+	}
+	erasure := domain.UserErasure{UserID: userID}
+
+	if erasure.QueuedWebhooks, err = s.rdb.DropUserWebhooks(ctx, userID); err != nil {
+		return erasure, fmt.Errorf("ошибка удаления вебхуков пользователя из очереди: %w", err)
+	}
+	if erasure.LivePosition, err = s.rdb.ForgetPosition(ctx, userID); err != nil {
+		return erasure, fmt.Errorf("ошибка удаления позиции пользователя: %w", err)
+	}
+	if erasure.Checks, erasure.Deliveries, err = s.repo.EraseUser(ctx, s.privacy.StoredUserIDs(userID)); err != nil {
+		return erasure, err
+	}
+	return erasure, nil
+}
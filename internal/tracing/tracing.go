@@ -0,0 +1,66 @@
+// Package tracing настраивает OpenTelemetry: экспорт спанов по OTLP и передачу trace context через очередь вебхуков
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName - имя сервиса в трейсах
+const ServiceName = "notifier"
+
+// Tracer отдаёт трейсер сервиса, пока Init не вызван (или экспорт выключен) он ничего не записывает
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
+
+// Init включает экспорт спанов по OTLP/HTTP, если задан endpoint, иначе остаётся no-op провайдер по умолчанию.
+// Остальные настройки экспортёра (заголовки, insecure и тд) берутся из стандартных переменных OTEL_EXPORTER_OTLP_*
+// Возвращает функцию, которая дописывает оставшиеся спаны при остановке сервиса
+func Init(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	//trace context передаём всегда, даже без экспорта - иначе потеряется контекст, пришедший от портала
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания OTLP экспортёра: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания ресурса трейсов: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Inject сохраняет trace context из ctx в map, чтобы передать его через очередь вместе с вебхуком
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract восстанавливает trace context, сохранённый через Inject
+func Extract(carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(carrier))
+}
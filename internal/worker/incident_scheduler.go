@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScheduleApplier описывает, что планировщик ждет от сервиса
+type ScheduleApplier interface {
+	ApplySchedule(ctx context.Context, now time.Time) error
+}
+
+// IncidentScheduler раз в interval включает и выключает запланированные инциденты
+type IncidentScheduler struct {
+	service  ScheduleApplier
+	interval time.Duration
+}
+
+func NewIncidentScheduler(service ScheduleApplier, interval int) *IncidentScheduler {
+	return &IncidentScheduler{
+		service:  service,
+		interval: time.Duration(interval) * time.Second, // ожидаем интервал в секундах
+	}
+}
+
+func (s *IncidentScheduler) Run(ctx context.Context) {
+	slog.InfoContext(ctx, "планировщик инцидентов запущен", slog.Duration("interval", s.interval))
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	//первый проход делаем сразу, чтобы не ждать интервал после рестарта
+	s.tick(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.tick(ctx)
+		}
+	}
+}
+
+func (s *IncidentScheduler) tick(ctx context.Context) {
+	//у каждого прохода свой ID, чтобы его логи и вебхуки связывались так же, как у HTTP запросов
+	ctx = domain.WithRequestID(ctx, uuid.NewString())
+	if err := s.service.ApplySchedule(ctx, time.Now()); err != nil {
+		slog.ErrorContext(ctx, "ошибка планировщика инцидентов", slog.Any("error", err))
+	}
+}
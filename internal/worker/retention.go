@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"RedCollar/internal/domain"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pruner описывает, что задача хранения ждет от сервиса
+type Pruner interface {
+	EnsurePartitions(ctx context.Context, now time.Time) error
+	Prune(ctx context.Context, now time.Time) error
+}
+
+// RetentionJob раз в interval создаёт секции location_checks на дни вперёд и удаляет проверки координат старше срока хранения
+type RetentionJob struct {
+	service  Pruner
+	interval time.Duration
+}
+
+func NewRetentionJob(service Pruner, interval int) *RetentionJob {
+	return &RetentionJob{
+		service:  service,
+		interval: time.Duration(interval) * time.Minute, // ожидаем интервал в минутах
+	}
+}
+
+func (j *RetentionJob) Run(ctx context.Context) {
+	slog.InfoContext(ctx, "задача хранения проверок запущена", slog.Duration("interval", j.interval))
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	//первый проход делаем сразу, чтобы после долгого простоя не ждать интервал и не писать проверки в секцию по умолчанию
+	j.tick(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.tick(ctx)
+		}
+	}
+}
+
+func (j *RetentionJob) tick(ctx context.Context) {
+	ctx = domain.WithRequestID(ctx, uuid.NewString())
+	now := time.Now()
+	//секции создаём до удаления: ошибка удаления не должна оставить следующий день без секции
+	if err := j.service.EnsurePartitions(ctx, now); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "ошибка создания секций location_checks", slog.Any("error", err))
+	}
+	if err := j.service.Prune(ctx, now); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "ошибка удаления старых проверок", slog.Any("error", err))
+	}
+}
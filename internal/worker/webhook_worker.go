@@ -2,35 +2,71 @@ package worker
 
 import (
 	"RedCollar/internal/domain"
+	"RedCollar/internal/i18n"
+	"RedCollar/internal/metrics"
 	"RedCollar/internal/repository"
+	"RedCollar/internal/tracing"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"sync/atomic"
 	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// TenantSource отдаёт организацию вебхука, чтобы доставить его по её адресу и с её настройками
+type TenantSource interface {
+	Get(ctx context.Context, id uuid.UUID) (*domain.Tenant, error)
+}
+
+// DeliveryRecorder сохраняет итог доставки вебхука
+type DeliveryRecorder interface {
+	SaveDelivery(ctx context.Context, d *domain.WebhookDelivery) error
+}
+
 type WebhookWorker struct {
 	redisRepo     repository.RedisRepository
+	tenants       TenantSource
+	deliveries    DeliveryRecorder
 	client        *http.Client
 	URL           string
 	retriesAmount int
+	timeout       time.Duration //таймаут одной попытки доставки, если у организации не задан свой
+	grace         time.Duration //сколько после остановки воркера ещё может идти начатая попытка доставки
+	heartbeat     atomic.Int64  //время последней итерации воркера в unix nano, по нему readiness проверяет что воркер жив
 }
 
-func NewWebhookWorker(redisRepo repository.RedisRepository, client *http.Client, url string, retries int) *WebhookWorker {
+// requeueTimeout - сколько ждём redis, возвращая недоставленный вебхук в очередь при остановке
+const requeueTimeout = 5 * time.Second
+
+// URL, retries и timeout - настройки по умолчанию, организация может переопределить каждую из них.
+// grace ограничивает, сколько начатая доставка продолжается после остановки воркера
+func NewWebhookWorker(redisRepo repository.RedisRepository, tenants TenantSource, deliveries DeliveryRecorder, client *http.Client, url string, retries int, timeout, grace time.Duration) *WebhookWorker {
 	return &WebhookWorker{
 		redisRepo:     redisRepo,
+		tenants:       tenants,
+		deliveries:    deliveries,
 		client:        client,
 		URL:           url,
 		retriesAmount: retries,
+		timeout:       timeout,
+		grace:         grace,
 	}
 }
 func (w *WebhookWorker) Run(ctx context.Context) {
-	log.Println("Webhook worker успешно запущен")
+	slog.InfoContext(ctx, "webhook worker запущен")
 	for {
+		w.beat()
 
 		//пытаемся получить вебхук из очереди
 		webhook, err := w.redisRepo.PopWebhook(ctx)
@@ -38,28 +74,200 @@ func (w *WebhookWorker) Run(ctx context.Context) {
 			if errors.Is(err, context.Canceled) { //если получили context.Canceled - выходим
 				return
 			}
-			log.Printf("Ошибка получения данных: %v\n", err) //если ошибка не связана с контекстом - логируем и делаем ретрай
+			if errors.Is(err, repository.ErrQueueEmpty) { //очередь пуста - просто ждём дальше
+				continue
+			}
+			slog.ErrorContext(ctx, "ошибка получения вебхука из очереди", slog.Any("error", err)) //если ошибка не связана с контекстом - логируем и делаем ретрай
 			continue
 		}
 
-		//при получении ошибки вызываем обёртку с ретраем, передаем кол-во из .env и делаем проверку на context.Canceled
-		if err := w.SendWithRetry(ctx, webhook, w.retriesAmount); err != nil {
-			if errors.Is(err, context.Canceled) { //получили context.Canceled - выходим
-				return
-			}
+		//вебхук уже снят с очереди: если сервис остановился раньше, чем его удалось доставить,
+		//возвращаем его в очередь, и его отправит следующий запуск
+		if err := w.deliver(ctx, webhook); errors.Is(err, context.Canceled) {
+			w.requeue(ctx, webhook)
+			return
+		}
+	}
+}
+
+// requeue возвращает недоставленный вебхук в очередь, ctx к этому моменту уже отменён
+func (w *WebhookWorker) requeue(ctx context.Context, webhook domain.Webhook) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), requeueTimeout)
+	defer cancel()
+	if err := w.redisRepo.RequeueWebhook(ctx, webhook); err != nil {
+		slog.ErrorContext(ctx, "не удалось вернуть вебхук в очередь при остановке", slog.Any("error", err),
+			slog.String("event", string(webhook.Event)), slog.String("incident_id", webhook.IncidentID.String()))
+		return
+	}
+	slog.InfoContext(ctx, "недоставленный вебхук возвращён в очередь",
+		slog.String("event", string(webhook.Event)), slog.String("incident_id", webhook.IncidentID.String()))
+}
+
+// withGrace отдаёт контекст, который переживает отмену ctx ещё на grace: начатая работа успевает закончиться
+// при остановке, но не задерживает её дольше grace
+func withGrace(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-graceCtx.Done():
 		}
+	})
+	return graceCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// beat отмечает, что воркер жив
+func (w *WebhookWorker) beat() {
+	w.heartbeat.Store(time.Now().UnixNano())
+}
+
+// LastHeartbeat отдаёт время последней итерации воркера, нулевое время - воркер ещё не запускался
+func (w *WebhookWorker) LastHeartbeat() time.Time {
+	nano := w.heartbeat.Load()
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// deliver отправляет вебхук в отдельном трейсе, который ссылается на трейс проверки, поставившей вебхук в очередь.
+// Доставка происходит позже и асинхронно, поэтому спан не дочерний, а связанный (link)
+func (w *WebhookWorker) deliver(ctx context.Context, webhook domain.Webhook) error {
+	origin := trace.SpanContextFromContext(tracing.Extract(webhook.TraceContext))
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		//воркер забирает вебхук из очереди, поэтому со стороны очереди он consumer
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("webhook.event", string(webhook.Event)),
+			attribute.String("incident.id", webhook.IncidentID.String()),
+			attribute.String("tenant.id", webhook.TenantID.String()),
+		),
+	}
+	//у вебхуков планировщика и поставленных до появления трейсинга trace context нет, связывать не с чем
+	if origin.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: origin}))
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "WebhookWorker.Deliver", opts...)
+	defer span.End()
+
+	//логи доставки и заголовок X-Request-ID связываем с запросом, из-за которого появился вебхук
+	if webhook.RequestID != "" {
+		ctx = domain.WithRequestID(ctx, webhook.RequestID)
+	}
+	//trace context и ID запроса нужны только внутри очереди, получателю отправляем исходное тело вебхука
+	requestID := webhook.RequestID
+	webhook.TraceContext = nil
+	webhook.RequestID = ""
+	//у вебхуков, поставленных в очередь до появления языка, он не записан - берём язык по умолчанию
+	lang, err := i18n.ParseLang(webhook.Lang)
+	if err != nil {
+		lang = i18n.FromContext(ctx)
+	}
+	i18n.Localize(&webhook, lang)
+
+	//настройки организации и итог доставки читаем и пишем и во время остановки, но не дольше w.grace
+	graceCtx, cancel := withGrace(ctx, w.grace)
+	defer cancel()
+
+	target := w.target(graceCtx, webhook.TenantID)
+	err = w.SendWithRetry(ctx, webhook, target)
+	//остановка прервала доставку - итог не записываем, вебхук вернётся в очередь
+	if err != nil && ctx.Err() != nil {
+		span.SetStatus(codes.Error, "доставка прервана остановкой воркера")
+		return ctx.Err()
+	}
+	w.record(graceCtx, webhook, target, requestID, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "не удалось доставить вебхук", slog.Any("error", err),
+				slog.String("event", string(webhook.Event)), slog.String("incident_id", webhook.IncidentID.String()))
+		}
+		return err
+	}
+	slog.DebugContext(ctx, "вебхук доставлен",
+		slog.String("event", string(webhook.Event)), slog.String("incident_id", webhook.IncidentID.String()))
+	return nil
+}
+
+// record сохраняет итог доставки, чтобы по запросу пользователя можно было показать, какие данные о нём ушли наружу
+func (w *WebhookWorker) record(ctx context.Context, webhook domain.Webhook, target Target, requestID string, sendErr error) {
+	delivery := &domain.WebhookDelivery{
+		TenantID:   webhook.TenantID,
+		Event:      webhook.Event,
+		UserID:     webhook.UserID,
+		IncidentID: webhook.IncidentID,
+		DetectedAt: webhook.DetectedAt,
+		URL:        target.URL,
+		Delivered:  sendErr == nil,
+		RequestID:  requestID,
+	}
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+	}
+	if err := w.deliveries.SaveDelivery(ctx, delivery); err != nil {
+		slog.WarnContext(ctx, "не удалось сохранить итог доставки вебхука", slog.Any("error", err))
+	}
+}
+
+// Target - куда и как доставлять вебхуки одной организации
+type Target struct {
+	URL     string
+	Retries int
+	Timeout time.Duration
+}
+
+// target собирает настройки доставки организации, незаданные берутся из конфигурации сервиса.
+// Если организацию не удалось прочитать, вебхук не теряем, а отправляем с настройками по умолчанию
+func (w *WebhookWorker) target(ctx context.Context, tenantID uuid.UUID) Target {
+	tenant, err := w.tenants.Get(ctx, tenantID)
+	if err != nil {
+		slog.WarnContext(ctx, "не удалось получить настройки организации для вебхука", slog.Any("error", err),
+			slog.String("tenant_id", tenantID.String()))
+		tenant = nil
+	}
+	return Target{
+		URL:     tenant.WebhookURLOr(w.URL),
+		Retries: tenant.WebhookRetriesOr(w.retriesAmount),
+		Timeout: tenant.WebhookTimeoutOr(w.timeout),
 	}
 }
 
 // SendNotification отвечает за процесс отправки вебхука
-func (w *WebhookWorker) SendNotification(webhook domain.Webhook) error {
+func (w *WebhookWorker) SendNotification(ctx context.Context, webhook domain.Webhook, target Target) error {
 	body, err := json.Marshal(webhook)
 	if err != nil {
 		return err
 	}
 
+	//таймаут задаём на попытку, а не клиенту: у организаций он может быть разным
+	if target.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, target.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if requestID := domain.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	//передаём получателю traceparent, чтобы его обработка попала в трейс доставки
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	//Постим тело вебхука
-	resp, err := w.client.Post(w.URL, "application/json", bytes.NewBuffer(body))
+	resp, err := w.client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -73,10 +281,19 @@ func (w *WebhookWorker) SendNotification(webhook domain.Webhook) error {
 }
 
 // SendWithRetry отвечает за вызов SendNotification с n ретраями
-func (w *WebhookWorker) SendWithRetry(ctx context.Context, webhook domain.Webhook, retries int) error {
+func (w *WebhookWorker) SendWithRetry(ctx context.Context, webhook domain.Webhook, target Target) error {
+	retries := target.Retries
 	for i := 1; i <= retries; i++ { //в цикле пытаемся отправить вебхук
-		err := w.SendNotification(webhook)
+		w.beat() //ретраи могут идти долго, воркер при этом жив
+		//начатая попытка не обрывается сразу при остановке, а получает ещё w.grace
+		attemptCtx, cancel := withGrace(ctx, w.grace)
+		err := w.SendNotification(attemptCtx, webhook, target)
+		cancel()
+		if err != nil {
+			slog.WarnContext(ctx, "ошибка отправки вебхука", slog.Any("error", err), slog.Int("attempt", i))
+		}
 		if err == nil {
+			metrics.WebhookDeliveries.WithLabelValues(metrics.ResultSuccess).Inc()
 			return nil //игнорируем ошибку, чтобы попасть в нижний блок с реализацией ретраев
 		}
 
@@ -87,15 +304,17 @@ func (w *WebhookWorker) SendWithRetry(ctx context.Context, webhook domain.Webhoo
 
 			select {
 			//здесь мы учитываем случай когда мы получили ctx.Done(), т.е. случай, когда главный контекст сказал выключаться
-			//мы останавливаем таймер и отдаём ctx.Err() в качестве логов
+			//мы останавливаем таймер и отдаём ctx.Err(), чтобы вебхук вернули в очередь, а не ждали следующей попытки
 			case <-ctx.Done():
 				timer.Stop()
 				return ctx.Err()
 			case <-timer.C:
 				//если таймер дотикал - выходим из селекта и снова делаем проверку, если проверка успешная - повторяем
+				metrics.WebhookRetries.Inc()
 				continue
 			}
 		}
 	}
+	metrics.WebhookDeliveries.WithLabelValues(metrics.ResultFailure).Inc()
 	return fmt.Errorf("спустя %v ретраев не удалось отправить вебхук %v", retries, webhook.IncidentID)
 }
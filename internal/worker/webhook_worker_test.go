@@ -0,0 +1,156 @@
+package worker
+
+import (
+	"RedCollar/internal/domain"
+	"RedCollar/internal/repository"
+	"RedCollar/internal/tracing"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// noTenants - организаций нет, вебхуки уходят с настройками по умолчанию
+type noTenants struct{}
+
+func (noTenants) Get(ctx context.Context, id uuid.UUID) (*domain.Tenant, error) {
+	return nil, domain.ErrTenantNotFound
+}
+
+// memDeliveries запоминает итоги доставки
+type memDeliveries struct {
+	mu    sync.Mutex
+	saved []*domain.WebhookDelivery
+}
+
+func (m *memDeliveries) SaveDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saved = append(m.saved, d)
+	return nil
+}
+
+// recordSpans подменяет глобальный провайдер трейсов на записывающий спаны в память
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := tracing.Init(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return recorder
+}
+
+func deliverySpan(t *testing.T, recorder *tracetest.SpanRecorder) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range recorder.Ended() {
+		if span.Name() == "WebhookWorker.Deliver" {
+			return span
+		}
+	}
+	t.Fatal("спан доставки не записан")
+	return nil
+}
+
+func newTestWorker(t *testing.T, handler http.HandlerFunc) *WebhookWorker {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewWebhookWorker(nil, noTenants{}, &memDeliveries{}, srv.Client(), srv.URL, 1, time.Second, time.Second)
+}
+
+func TestDeliverSpanLinksOrigin(t *testing.T) {
+	recorder := recordSpans(t)
+	w := newTestWorker(t, func(http.ResponseWriter, *http.Request) {})
+
+	//проверка координат, которая поставила вебхук в очередь
+	ctx, origin := otel.Tracer("test").Start(context.Background(), "checkLocation")
+	webhook := domain.Webhook{Event: domain.EventUserInZone, IncidentID: uuid.New(), TraceContext: tracing.Inject(ctx)}
+	origin.End()
+
+	if err := w.deliver(context.Background(), webhook); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	span := deliverySpan(t, recorder)
+	if span.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("вид спана %v, ожидался consumer", span.SpanKind())
+	}
+	if links := span.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != origin.SpanContext().SpanID() {
+		t.Errorf("спан доставки не ссылается на проверку координат: %+v", links)
+	}
+}
+
+func TestDeliverSpanWithoutTraceContextHasNoLinks(t *testing.T) {
+	recorder := recordSpans(t)
+	w := newTestWorker(t, func(http.ResponseWriter, *http.Request) {})
+
+	webhook := domain.Webhook{Event: domain.EventIncidentExpired, IncidentID: uuid.New()}
+	if err := w.deliver(context.Background(), webhook); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if links := deliverySpan(t, recorder).Links(); len(links) != 0 {
+		t.Errorf("ожидался спан без ссылок, получено %+v", links)
+	}
+}
+
+func TestStopRequeuesUndeliveredWebhook(t *testing.T) {
+	m := miniredis.RunT(t)
+	rdb, err := repository.RedisConnection(context.Background(), m.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+
+	attempts := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		attempts <- struct{}{}
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+	deliveries := &memDeliveries{}
+	//между попытками ждали бы секунды, остановка не должна их дожидаться
+	w := NewWebhookWorker(rdb, noTenants{}, deliveries, srv.Client(), srv.URL, 5, time.Second, 100*time.Millisecond)
+
+	tenantID := uuid.New()
+	webhook := domain.Webhook{Event: domain.EventUserInZone, IncidentID: uuid.New(), TenantID: tenantID}
+	if err := rdb.WebhookPush(context.Background(), webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-attempts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("воркер не начал доставку")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("воркер ждёт следующей попытки вместо остановки")
+	}
+
+	queued, err := m.List("webhook_q:" + tenantID.String())
+	if err != nil || len(queued) != 1 {
+		t.Fatalf("в очереди организации %v (ошибка %v), ожидали вернувшийся вебхук", queued, err)
+	}
+	if len(deliveries.saved) != 0 {
+		t.Fatalf("прерванная доставка записана как итог: %+v", deliveries.saved[0])
+	}
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_incidents_severity;
+DROP INDEX IF EXISTS idx_incidents_category;
+
+ALTER TABLE incidents DROP COLUMN IF EXISTS severity;
+ALTER TABLE incidents DROP COLUMN IF EXISTS category;
@@ -0,0 +1,5 @@
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS category VARCHAR(32) NOT NULL DEFAULT 'other';
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS severity SMALLINT NOT NULL DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS idx_incidents_category ON incidents(category);
+CREATE INDEX IF NOT EXISTS idx_incidents_severity ON incidents(severity);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_incidents_valid_until;
+DROP INDEX IF EXISTS idx_incidents_valid_from;
+
+ALTER TABLE incidents DROP COLUMN IF EXISTS valid_until;
+ALTER TABLE incidents DROP COLUMN IF EXISTS valid_from;
@@ -0,0 +1,5 @@
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS valid_from TIMESTAMP WITH TIME ZONE;
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS valid_until TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_incidents_valid_from ON incidents(valid_from) WHERE valid_from IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_incidents_valid_until ON incidents(valid_until) WHERE valid_until IS NOT NULL;
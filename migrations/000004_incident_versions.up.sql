@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS incident_versions (
+    id            BIGSERIAL PRIMARY KEY,
+    incident_id   UUID NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+    version       INT NOT NULL,
+    action        VARCHAR(32) NOT NULL,
+    actor         VARCHAR(255) NOT NULL,
+    old_value     JSONB,
+    new_value     JSONB NOT NULL,
+    restored_from INT,
+    changed_at    TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (incident_id, version)
+);
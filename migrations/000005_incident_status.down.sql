@@ -0,0 +1,11 @@
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS is_active BOOLEAN DEFAULT TRUE;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'incidents' AND column_name = 'status') THEN
+        UPDATE incidents SET is_active = (status = 'active');
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS idx_incidents_status;
+ALTER TABLE incidents DROP COLUMN IF EXISTS status;
@@ -0,0 +1,11 @@
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active';
+
+UPDATE incidents SET status = CASE
+    WHEN is_active THEN 'active'
+    WHEN valid_from IS NOT NULL AND valid_from > NOW() THEN 'scheduled'
+    ELSE 'resolved'
+END;
+
+ALTER TABLE incidents DROP COLUMN IF EXISTS is_active;
+
+CREATE INDEX IF NOT EXISTS idx_incidents_status ON incidents(status);
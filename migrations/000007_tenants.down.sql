@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_api_keys_tenant;
+DROP INDEX IF EXISTS idx_location_checks_tenant_time;
+DROP INDEX IF EXISTS idx_incidents_tenant;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE location_checks DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE incidents DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS tenants (
+    id                   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug                 VARCHAR(64) NOT NULL UNIQUE,
+    name                 VARCHAR(255) NOT NULL,
+    warning_zone         DOUBLE PRECISION,
+    stats_window_minutes INTEGER,
+    webhook_url          TEXT,
+    webhook_retries      INTEGER,
+    webhook_timeout      INTEGER,
+    created_at           TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- все данные, созданные до появления организаций, принадлежат организации по умолчанию
+INSERT INTO tenants (id, slug, name) VALUES ('00000000-0000-0000-0000-000000000001', 'default', 'Default')
+ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS tenant_id UUID NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES tenants(id);
+ALTER TABLE location_checks ADD COLUMN IF NOT EXISTS tenant_id UUID NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES tenants(id);
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id UUID NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES tenants(id);
+
+CREATE INDEX IF NOT EXISTS idx_incidents_tenant ON incidents(tenant_id, status);
+CREATE INDEX IF NOT EXISTS idx_location_checks_tenant_time ON location_checks(tenant_id, checked_at);
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant ON api_keys(tenant_id);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id           BIGSERIAL PRIMARY KEY,
+    tenant_id    UUID NOT NULL REFERENCES tenants(id),
+    actor_key_id UUID,
+    actor        VARCHAR(255) NOT NULL,
+    method       VARCHAR(16) NOT NULL,
+    route        VARCHAR(255) NOT NULL,
+    incident_id  UUID,
+    body_sha256  CHAR(64) NOT NULL,
+    ip           VARCHAR(64) NOT NULL,
+    status       INTEGER NOT NULL,
+    request_id   VARCHAR(128) NOT NULL DEFAULT '',
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_tenant_time ON audit_log(tenant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_incident ON audit_log(incident_id) WHERE incident_id IS NOT NULL;
+
+-- журнал только дополняется: изменить или удалить запись нельзя даже с правами сервиса
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,11 @@
+-- почасовые итоги проверок координат по инцидентам, остаются после удаления сырых строк location_checks
+CREATE TABLE IF NOT EXISTS location_stats_hourly (
+    tenant_id    UUID NOT NULL REFERENCES tenants(id),
+    incident_id  UUID NOT NULL,
+    hour         TIMESTAMP WITH TIME ZONE NOT NULL,
+    checks       BIGINT NOT NULL,
+    unique_users BIGINT NOT NULL,
+    PRIMARY KEY (tenant_id, incident_id, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_location_stats_hourly_hour ON location_stats_hourly(tenant_id, hour);
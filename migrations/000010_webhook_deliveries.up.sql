@@ -0,0 +1,16 @@
+-- итог доставки каждого вебхука, нужен чтобы отвечать на запросы пользователей о их данных
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id           BIGSERIAL PRIMARY KEY,
+    tenant_id    UUID NOT NULL REFERENCES tenants(id),
+    event        VARCHAR(64) NOT NULL,
+    user_id      VARCHAR(255) NOT NULL DEFAULT '',
+    incident_id  UUID NOT NULL,
+    detected_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    url          TEXT NOT NULL,
+    delivered    BOOLEAN NOT NULL,
+    error        TEXT NOT NULL DEFAULT '',
+    request_id   VARCHAR(128) NOT NULL DEFAULT '',
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_user ON webhook_deliveries(tenant_id, user_id) WHERE user_id <> '';
@@ -0,0 +1,2 @@
+-- пользователь, над данными которого выполнялось действие (выгрузка или удаление), всегда его псевдоним
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS target VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- почасовые итоги проверок координат по ячейкам самой мелкой сетки тепловой карты (geohash точности 8),
+-- lat и lon - центр ячейки; остаются после удаления сырых строк location_checks
+CREATE TABLE IF NOT EXISTS location_heatmap_hourly (
+    tenant_id    UUID NOT NULL REFERENCES tenants(id),
+    hour         TIMESTAMP WITH TIME ZONE NOT NULL,
+    lat          DOUBLE PRECISION NOT NULL,
+    lon          DOUBLE PRECISION NOT NULL,
+    checks       BIGINT NOT NULL,
+    unique_users BIGINT NOT NULL,
+    in_danger    BIGINT NOT NULL,
+    PRIMARY KEY (tenant_id, hour, lat, lon)
+);
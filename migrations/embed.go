@@ -0,0 +1,9 @@
+// Package migrations встраивает SQL миграции в бинарник, чтобы схема обновлялась без доступа к файлам исходников
+package migrations
+
+import "embed"
+
+// FS содержит все файлы миграций в формате golang-migrate: <версия>_<имя>.up.sql / .down.sql
+//
+//go:embed *.sql
+var FS embed.FS